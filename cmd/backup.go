@@ -0,0 +1,99 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/repository"
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup [file]",
+	Short: "Write a consistent snapshot of the database",
+	Long: `Write a consistent snapshot of the database, safe to run while
+the timer is in use. Without a file the snapshot is stored in the
+backup directory with the current time in its name.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+
+		dest := ""
+		if len(args) > 0 {
+			dest = args[0]
+		} else {
+			if err := os.MkdirAll(backupDir(), 0o755); err != nil {
+				return err
+			}
+			ext := filepath.Ext(viper.GetString("db"))
+			base := strings.TrimSuffix(filepath.Base(viper.GetString("db")), ext)
+			dest = filepath.Join(backupDir(),
+				fmt.Sprintf("%s-%s%s", base, time.Now().Format("20060102-150405"), ext))
+		}
+		return backupAction(cmd.Context(), cmd.OutOrStdout(), repo, dest)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+
+	viper.SetDefault("backup.daily", false)
+	viper.SetDefault("backup.dir", "")
+	viper.SetDefault("backup.keep", 7)
+}
+
+func getBackuper(repo models.Repository) (models.Backuper, error) {
+	b, ok := repo.(models.Backuper)
+	if !ok {
+		return nil, fmt.Errorf("repository %T does not support backups", repo)
+	}
+	return b, nil
+}
+
+// Backups go next to the database unless configured
+func backupDir() string {
+	if dir := viper.GetString("backup.dir"); dir != "" {
+		return dir
+	}
+	return filepath.Join(filepath.Dir(viper.GetString("db")), "backups")
+}
+
+func backupAction(ctx context.Context, out io.Writer, repo models.Repository, dest string) error {
+	b, err := getBackuper(repo)
+	if err != nil {
+		return err
+	}
+	if err := b.Backup(ctx, dest); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, "Backup written to", dest)
+	return err
+}
+
+// Take the daily backup if enabled in the config
+func autoBackup(ctx context.Context, repo models.Repository) error {
+	if !viper.GetBool("backup.daily") {
+		return nil
+	}
+	b, err := getBackuper(repo)
+	if err != nil {
+		return err
+	}
+	_, err = repository.DailyBackup(ctx, b, backupDir(),
+		filepath.Base(viper.GetString("db")), time.Now(), viper.GetInt("backup.keep"))
+	return err
+}
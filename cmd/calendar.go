@@ -0,0 +1,41 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"github.com/xor111xor/pomodoro-go/internal/calendar"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+func init() {
+	viper.SetDefault("calendar.files", []string{})
+	viper.SetDefault("calendar.shorten", false)
+	viper.SetDefault("calendar.workday", "")
+}
+
+func checkWorkday(v any) error {
+	s, err := cast.ToStringE(v)
+	if err != nil {
+		return err
+	}
+	_, _, err = models.ParseWorkday(s)
+	return err
+}
+
+// Plan the intervals of config around the meetings in the configured
+// calendar files
+func setCalendar(config *models.IntervalConfig) error {
+	var err error
+	config.WorkdayStart, config.WorkdayEnd, err = models.ParseWorkday(viper.GetString("calendar.workday"))
+	if err != nil {
+		return err
+	}
+	config.ShortenForEvents = viper.GetBool("calendar.shorten")
+	if files := viper.GetStringSlice("calendar.files"); len(files) > 0 {
+		config.Calendar = calendar.New(config.Location, files...)
+	}
+	return nil
+}
@@ -0,0 +1,495 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/xor111xor/pomodoro-go/internal/hooks"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Create, show and check the configuration",
+	Long: `Settings are taken from flags, then environment variables, then
+the config file, then defaults. Environment variables are the upper
+case keys with '.' and '-' replaced by '_', e.g. BACKUP_KEEP.`,
+	// Report broken config files instead of refusing to run
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return nil
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a commented config file with the defaults",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			return err
+		}
+
+		path := cfgFile
+		if path == "" {
+			dir, err := configDir()
+			if err != nil {
+				return err
+			}
+			path = filepath.Join(dir, "pomodoro-go.yaml")
+		}
+		return configInitAction(cmd.OutOrStdout(), path, force)
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration and where each value comes from",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cfgErr != nil {
+			return cfgErr
+		}
+		return configShowAction(cmd.OutOrStdout())
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration for invalid values and unknown keys",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cfgErr != nil {
+			return cfgErr
+		}
+		return configValidateAction(cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configInitCmd, configShowCmd, configValidateCmd)
+
+	configInitCmd.Flags().Bool("force", false, "Overwrite an existing config file")
+}
+
+// Known configuration key and check of its value
+type configKey struct {
+	name  string
+	check func(v any) error
+}
+
+var configKeys = []configKey{
+	{"db", checkString},
+	{"timezone", func(v any) error {
+		s, err := cast.ToStringE(v)
+		if err != nil {
+			return err
+		}
+		_, err = time.LoadLocation(s)
+		return err
+	}},
+	{"day-start", func(v any) error {
+		s, err := cast.ToStringE(v)
+		if err != nil {
+			return err
+		}
+		_, err = models.ParseDayStart(s)
+		return err
+	}},
+	{"pomo", checkDuration},
+	{"long", checkDuration},
+	{"short", checkDuration},
+	{"socket", checkString},
+	{"task", checkString},
+	{"project", checkString},
+	{"tags", func(v any) error {
+		_, err := cast.ToStringSliceE(v)
+		return err
+	}},
+	{"backup.daily", func(v any) error {
+		_, err := cast.ToBoolE(v)
+		return err
+	}},
+	{"backup.dir", checkString},
+	{"serve.addr", func(v any) error {
+		s, err := cast.ToStringE(v)
+		if err != nil {
+			return err
+		}
+		return checkLoopback(s)
+	}},
+	{"serve.token", checkString},
+	{"hooks.timeout", checkDuration},
+	{"hooks." + hooks.EventPomodoroStart, checkHookCommands},
+	{"hooks." + hooks.EventBreakStart, checkHookCommands},
+	{"hooks." + hooks.EventPause, checkHookCommands},
+	{"hooks." + hooks.EventComplete, checkHookCommands},
+	{"hooks." + hooks.EventSkip, checkHookCommands},
+	{"hooks." + hooks.EventCancel, checkHookCommands},
+	{"calendar.files", func(v any) error {
+		_, err := cast.ToStringSliceE(v)
+		return err
+	}},
+	{"calendar.shorten", checkBool},
+	{"calendar.workday", checkWorkday},
+	{"git.enabled", checkBool},
+	{"taskwarrior.enabled", checkBool},
+	{"taskwarrior.filter", checkString},
+	{"todotxt.file", checkString},
+	{"terminal.bell", checkBool},
+	{"terminal.osc9", checkBool},
+	{"terminal.osc777", checkBool},
+	{"terminal.title", checkBool},
+	{"mqtt.broker", checkBroker},
+	{"mqtt.client-id", checkString},
+	{"mqtt.username", checkString},
+	{"mqtt.password", checkString},
+	{"mqtt.topic", checkString},
+	{"mqtt.qos", checkQoS},
+	{"mqtt.ticks", checkBool},
+	{"mqtt.ca", checkString},
+	{"mqtt.cert", checkString},
+	{"mqtt.key", checkString},
+	{"mqtt.insecure", checkBool},
+	{"notify.enabled", checkBool},
+	{"notify.pomodoro.title", checkNotifyTemplate},
+	{"notify.pomodoro.body", checkNotifyTemplate},
+	{"notify.break.title", checkNotifyTemplate},
+	{"notify.break.body", checkNotifyTemplate},
+	{"notify.snooze", checkDuration},
+	{"webhooks", func(v any) error {
+		_, err := decodeWebhooks(v)
+		return err
+	}},
+	{"backup.keep", func(v any) error {
+		n, err := cast.ToIntE(v)
+		if err == nil && n < 1 {
+			err = fmt.Errorf("must be at least 1")
+		}
+		return err
+	}},
+}
+
+// Keys whose values are not shown
+var secretKeys = map[string]bool{
+	"serve.token":   true,
+	"mqtt.password": true,
+}
+
+// Return v of key for printing with secrets replaced by ***
+func redact(key string, v any) any {
+	if secretKeys[key] {
+		if cast.ToString(v) == "" {
+			return v
+		}
+		return "***"
+	}
+
+	hooks, ok := v.([]any)
+	if key != "webhooks" || !ok {
+		return v
+	}
+	shown := make([]any, len(hooks))
+	for n, h := range hooks {
+		m, ok := h.(map[string]any)
+		if !ok {
+			shown[n] = h
+			continue
+		}
+		c := map[string]any{}
+		for k, x := range m {
+			if strings.EqualFold(k, "secret") && cast.ToString(x) != "" {
+				x = "***"
+			}
+			c[k] = x
+		}
+		shown[n] = c
+	}
+	return shown
+}
+
+func checkHookCommands(v any) error {
+	_, err := hookCommands(v)
+	return err
+}
+
+func checkBool(v any) error {
+	_, err := cast.ToBoolE(v)
+	return err
+}
+
+func checkString(v any) error {
+	_, err := cast.ToStringE(v)
+	return err
+}
+
+// Durations are strings like 25m, numbers would be taken as nanoseconds
+func checkDuration(v any) error {
+	var d time.Duration
+	var err error
+	switch v := v.(type) {
+	case time.Duration:
+		d = v
+	case string:
+		d, err = time.ParseDuration(v)
+	default:
+		err = fmt.Errorf("want a duration like 25m, got %v", v)
+	}
+	if err == nil && d <= 0 {
+		err = fmt.Errorf("must be positive")
+	}
+	return err
+}
+
+// Check effective values of the known keys
+func checkConfig() []error {
+	problems := []error{}
+	for _, k := range configKeys {
+		v := viper.Get(k.name)
+		if err := k.check(v); err != nil {
+			problems = append(problems, fmt.Errorf("%s: invalid value %q from %s: %w",
+				k.name, fmt.Sprint(redact(k.name, v)), configSource(k.name), err))
+		}
+	}
+	return problems
+}
+
+func knownConfigKey(key string) bool {
+	for _, k := range configKeys {
+		if k.name == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Flags bound to configuration keys, of the root and of subcommands
+var boundFlags = map[string]*pflag.Flag{}
+
+// Bind key to flag f and remember it for configSource
+func bindFlag(key string, f *pflag.Flag) {
+	viper.BindPFlag(key, f)
+	boundFlags[key] = f
+}
+
+// Return where the effective value of key comes from
+func configSource(key string) string {
+	if f := boundFlags[key]; f != nil && f.Changed {
+		return "flag"
+	}
+	if _, ok := os.LookupEnv(strings.ToUpper(envKeyReplacer.Replace(key))); ok {
+		return "env"
+	}
+	if _, ok := projectSettings[key]; ok {
+		return "project"
+	}
+	if viper.InConfig(key) {
+		return "file"
+	}
+	return "default"
+}
+
+const configTemplate = `# pomodoro-go configuration
+#
+# Flags and environment variables take precedence over this file.
+# Environment variables are the upper case keys with '.' and '-'
+# replaced by '_', e.g. BACKUP_KEEP=14.
+
+# Database file
+db: pomo.db
+
+# Timezone for daily summaries, empty for local time
+timezone: ""
+
+# Time of day when a new day starts for summaries, e.g. "04:00"
+day-start: "00:00"
+
+# Durations of pomodoros and breaks
+pomo: 25m
+long: 15m
+short: 5m
+
+# Socket of the daemon, empty for $XDG_RUNTIME_DIR/pomodoro-go.sock
+socket: ""
+
+# Task and tags recorded on new pomodoros
+task: ""
+tags: []
+
+# Project recorded on new intervals. A .pomodoro.yaml file in the working
+# directory or its parents may set project, task, tags, pomo, long and
+# short over this file, the project defaults to the name of its directory.
+project: ""
+
+backup:
+  # Snapshot the database once a day on start
+  daily: false
+  # Directory of snapshots, default is backups next to the database
+  dir: ""
+  # Number of daily snapshots kept
+  keep: 7
+
+serve:
+  # Address of the HTTP API, always on localhost
+  addr: localhost:8425
+  # Bearer token required from clients, empty for none
+  token: ""
+
+# Shell commands run on pomodoro_start, break_start (also on resume),
+# pause, complete, skip and cancel, with the interval in POMODORO_* environment
+# variables. Output is logged next to the database, e.g.
+#   complete: notify-send "$POMODORO_CATEGORY done"
+#   pomodoro_start: [ "light on", "chat-status busy" ]
+hooks:
+  timeout: 10s
+
+# Meetings in iCalendar files, e.g. exported or synced by vdirsyncer.
+# Pomodoros running into the next meeting are reported, or shortened to
+# end before it. Meetings during the workday (e.g. "09:00-17:00") are
+# taken from the capacity in the daily summary.
+calendar:
+  files: []
+  shorten: false
+  workday: ""
+
+# Record the HEAD of the git repository in the working directory when
+# intervals start and end, for report --commits
+git:
+  enabled: false
+
+# Offer pending Taskwarrior tasks matching filter, like "+work", in the
+# dashboard (t)ask button. Tasks are started and stopped with their
+# pomodoros, the "pomodoros" UDA counts the completed ones.
+taskwarrior:
+  enabled: false
+  filter: ""
+
+# Offer the open tasks of a todo.txt file in the dashboard (t)ask button,
+# by priority. Completed pomodoros are counted in a pomo:N tag of their
+# task, and (x) done marks the task done.
+todotxt:
+  file: ""
+
+# Escape sequences written by the dashboard, also seen from a background
+# tmux window: bell and OSC 9 / OSC 777 notifications when an interval is
+# done, remaining time in the window title (OSC 0)
+terminal:
+  bell: true
+  osc9: false
+  osc777: false
+  title: true
+
+# MQTT broker the daemon and serve publish the timer to, retained under
+# <topic>/state, remaining, category, status and online, and take start,
+# resume, pause, stop and skip from on <topic>/command. Empty broker
+# disables, ssl:// or wss:// brokers are verified with the ca file.
+mqtt:
+  broker: ""  # e.g. tcp://localhost:1883
+  client-id: ""
+  username: ""
+  password: ""
+  topic: ""  # default pomodoro/<user>
+  qos: 0
+  ticks: false  # also publish remaining every second
+  ca: ""
+  cert: ""
+  key: ""
+  insecure: false
+
+# Desktop notifications over D-Bus when intervals are done. Titles and
+# bodies are templates of .Category, .Task, .Tags, .Actual, .Minutes and
+# .Next; the dashboard and the daemon add start and snooze actions.
+notify:
+  enabled: false
+  pomodoro:
+    title: Pomodoro done
+    body: "{{.Minutes}} minutes of focus{{if .Task}} on {{.Task}}{{end}}, time for a break."
+  break:
+    title: Break over
+    body: Ready for the next pomodoro?
+  snooze: 5m
+
+# Webhooks POSTed on start, resume, pause, complete, skip and cancel of
+# intervals, queued next to the database until delivered, e.g.
+#   - url: http://localhost:9000/pomodoro
+#     events: [start, complete]  # default all
+#     secret: ""                 # signs bodies in X-Pomodoro-Signature
+#     timeout: 5s
+#     retries: 5
+webhooks: []
+`
+
+func configInitAction(out io.Writer, path string, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s: %w, use --force to overwrite", path, os.ErrExist)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(configTemplate), 0o644); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(out, "Config written to", path)
+	return err
+}
+
+func configShowAction(out io.Writer) error {
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		file = "none"
+	}
+	fmt.Fprintln(out, "Config file:", file)
+	if projectFile != "" {
+		fmt.Fprintln(out, "Project file:", projectFile)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, k := range configKeys {
+		fmt.Fprintf(w, "%s\t%v\t%s\n", k.name, redact(k.name, viper.Get(k.name)), configSource(k.name))
+	}
+	return w.Flush()
+}
+
+func configValidateAction(out io.Writer) error {
+	problems := []error{}
+
+	// Unknown keys are only looked for in the file, viper knows
+	// nothing about other environment variables
+	if file := viper.ConfigFileUsed(); file != "" {
+		v := viper.New()
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err == nil {
+			keys := v.AllKeys()
+			sort.Strings(keys)
+			for _, key := range keys {
+				if !knownConfigKey(key) {
+					problems = append(problems, fmt.Errorf("%s: unknown key %q", file, key))
+				}
+			}
+		}
+	}
+
+	problems = append(problems, checkConfig()...)
+	if len(problems) > 0 {
+		return errors.Join(problems...)
+	}
+	_, err := fmt.Fprintln(out, "Configuration is valid")
+	return err
+}
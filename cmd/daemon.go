@@ -0,0 +1,176 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// daemonCmd represents the daemon command
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run the timer in the background and serve clients",
+	Long: `Run the timer in a long-lived process listening on a Unix socket,
+by default $XDG_RUNTIME_DIR/pomodoro-go.sock.
+
+While the daemon is running, the dashboard and the start, resume,
+pause, stop, skip and status commands control it instead of ticking
+intervals themselves, so intervals survive closing the terminal.
+Stopping the daemon cancels the running interval.
+
+The protocol is line-delimited JSON, see the documentation of the
+internal/daemon package.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		background, err := cmd.Flags().GetBool("background")
+		if err != nil {
+			return err
+		}
+		if background && os.Getenv(detachedEnv) == "" {
+			return detach(cmd.OutOrStdout())
+		}
+
+		config, err := getConfig()
+		if err != nil {
+			return err
+		}
+		if err := autoBackup(cmd.Context(), config.Repo); err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return daemonAction(ctx, os.Stderr, config, socketPath())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+
+	daemonCmd.Flags().BoolP("background", "b", false, "Run the daemon in a background process")
+}
+
+func socketPath() string {
+	if path := viper.GetString("socket"); path != "" {
+		return path
+	}
+	return daemon.SocketPath()
+}
+
+// Return client of the running daemon, nil without one. Pomodoros it
+// starts record the project, task and tags of this process.
+func daemonClient() *daemon.Client {
+	c, err := daemon.Dial(socketPath())
+	if err != nil {
+		return nil
+	}
+	c.Origin = func() daemon.Origin { return origin(nil) }
+	return c
+}
+
+// Return context of this process for the daemon, with the task picked
+// from picked if any
+func origin(picked *models.PickedTask) daemon.Origin {
+	o := daemon.Origin{
+		Project: viper.GetString("project"),
+		Task:    viper.GetString("task"),
+		Tags:    viper.GetStringSlice("tags"),
+	}
+	if t, ok := picked.Get(); ok {
+		o.Task = t.Description
+	}
+	return o
+}
+
+func daemonAction(ctx context.Context, out io.Writer, config *models.IntervalConfig, path string) error {
+	l, err := daemon.Listen(path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	fmt.Fprintln(out, "Listening on", path)
+	runWebhooks(ctx)
+	s := daemon.NewServer(config)
+	notifyStart(func(ctx context.Context) error {
+		_, err := s.Do(ctx, daemon.CmdStart)
+		return err
+	})
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	waitMQTT, err := runMQTT(ctx, out, s)
+	if err != nil {
+		l.Close()
+		return err
+	}
+	defer func() {
+		cancel()
+		waitMQTT()
+	}()
+	return s.Serve(ctx, l)
+}
+
+// Start or resume an interval in the daemon and follow it until it
+// is finished, paused or stopped unless detach is set
+func daemonRun(ctx context.Context, out io.Writer, c *daemon.Client, cmd string, detach bool) error {
+	if cmd == daemon.CmdStart {
+		s, err := c.Do(ctx, daemon.CmdStatus)
+		if err != nil {
+			return err
+		}
+		if s.State == models.StateRunning {
+			_, err := fmt.Fprintf(out, "%s already running, %s left\n", s.Category, s.Remaining)
+			return err
+		}
+	}
+
+	s, err := c.Do(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s started, %s left\n", s.Category, s.Remaining)
+	if detach {
+		return nil
+	}
+
+	followCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	err = c.Subscribe(followCtx, func(ev daemon.Event) {
+		if ev.Status.ID != s.ID {
+			return
+		}
+		switch ev.Name {
+		case daemon.EventDone:
+			fmt.Fprintf(out, "%s done\n", ev.Status.Category)
+		case daemon.EventStatus:
+			// Finished before we subscribed
+			if ev.Status.State == models.StateRunning {
+				return
+			}
+			fmt.Fprintf(out, "%s %s\n", ev.Status.Category, models.StateName(ev.Status.State))
+		case daemon.EventPause, daemon.EventStop, daemon.EventSkip:
+			fmt.Fprintf(out, "%s %s\n", ev.Status.Category, models.StateName(ev.Status.State))
+		default:
+			return
+		}
+		cancel()
+	})
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintf(out, "%s keeps running in the daemon\n", s.Category)
+	}
+	return nil
+}
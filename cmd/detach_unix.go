@@ -0,0 +1,9 @@
+//go:build !windows
+
+package cmd
+
+import "syscall"
+
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package cmd
+
+import "syscall"
+
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xor111xor/pomodoro-go/internal/export"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write intervals as CSV, JSON or iCalendar",
+	Long: `Write intervals for spreadsheets and calendars, one row,
+array element or VEVENT per pomodoro and break.
+
+The whole history is exported unless a range of days is given with
+--from and --to, both dates included, or --last, e.g. 30d or 4w.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+		fromFlag, err := cmd.Flags().GetString("from")
+		if err != nil {
+			return err
+		}
+		toFlag, err := cmd.Flags().GetString("to")
+		if err != nil {
+			return err
+		}
+		last, err := cmd.Flags().GetString("last")
+		if err != nil {
+			return err
+		}
+
+		config, err := getConfig()
+		if err != nil {
+			return err
+		}
+
+		// Whole history by default
+		start, end := time.Unix(0, 0), time.Now().AddDate(0, 0, 1)
+		if fromFlag != "" || toFlag != "" || last != "" {
+			if last == "" && fromFlag == "" {
+				last = "7d"
+			}
+			from, to, err := reportRange(config, time.Now(), fromFlag, toFlag, last)
+			if err != nil {
+				return err
+			}
+			start, _ = config.DateBounds(from)
+			_, end = config.DateBounds(to)
+		}
+
+		if output == "" || output == "-" {
+			return exportAction(cmd.Context(), cmd.OutOrStdout(), config, format, start, end)
+		}
+
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := exportAction(cmd.Context(), f, config, format, start, end); err != nil {
+			return err
+		}
+		return f.Close()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringP("format", "f", export.FormatCSV, "Output format: csv, json or ics")
+	exportCmd.Flags().StringP("output", "o", "", "Output file (default stdout)")
+	exportCmd.Flags().String("from", "", "First day to export, YYYY-MM-DD")
+	exportCmd.Flags().String("to", "", "Last day to export, YYYY-MM-DD (default today)")
+	exportCmd.Flags().String("last", "", "Export the last days or weeks, e.g. 30d or 4w")
+}
+
+// Stream intervals started in [start, end) to out
+func exportAction(ctx context.Context, out io.Writer, config *models.IntervalConfig,
+	format string, start, end time.Time) error {
+	w, err := export.NewWriter(format, out)
+	if err != nil {
+		return err
+	}
+	if err := config.Repo.Range(ctx, start, end, w.Write); err != nil {
+		return err
+	}
+	return w.Close()
+}
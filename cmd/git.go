@@ -0,0 +1,38 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/viper"
+	"github.com/xor111xor/pomodoro-go/internal/git"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+func init() {
+	viper.SetDefault("git.enabled", false)
+}
+
+// Record HEAD of the repository in the working directory on the
+// intervals of config, nothing outside of repositories
+func setGit(config *models.IntervalConfig) error {
+	if !viper.GetBool("git.enabled") {
+		return nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	repo, err := git.Open(wd)
+	if errors.Is(err, git.ErrNotRepository) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	config.Git = repo
+	return nil
+}
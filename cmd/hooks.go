@@ -0,0 +1,101 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/viper"
+	"github.com/xor111xor/pomodoro-go/internal/hooks"
+)
+
+// Runner of the configured shell hooks, nil without any
+var hookRunner *hooks.Runner
+
+func init() {
+	viper.SetDefault("hooks.timeout", hooks.DefaultTimeout)
+	for _, key := range hookKeys {
+		viper.SetDefault(key, []string{})
+	}
+}
+
+// Config keys of the hook events
+var hookKeys = []string{
+	"hooks." + hooks.EventPomodoroStart,
+	"hooks." + hooks.EventBreakStart,
+	"hooks." + hooks.EventPause,
+	"hooks." + hooks.EventComplete,
+	"hooks." + hooks.EventSkip,
+	"hooks." + hooks.EventCancel,
+}
+
+// Commands of a hook event are a string or a list of strings
+func hookCommands(v any) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []any:
+		commands := []string{}
+		for _, c := range v {
+			s, ok := c.(string)
+			if !ok {
+				return nil, fmt.Errorf("want a command, got %v", c)
+			}
+			commands = append(commands, s)
+		}
+		return commands, nil
+	}
+	return nil, fmt.Errorf("want a command or a list of commands, got %v", v)
+}
+
+// Return runner of the configured hooks, nil without any
+func getHooks() (*hooks.Runner, error) {
+	if hookRunner != nil {
+		return hookRunner, nil
+	}
+
+	config := hooks.Config{Timeout: viper.GetDuration("hooks.timeout")}
+	commands := []*[]string{
+		&config.PomodoroStart,
+		&config.BreakStart,
+		&config.Pause,
+		&config.Complete,
+		&config.Skip,
+		&config.Cancel,
+	}
+	for n, key := range hookKeys {
+		c, err := hookCommands(viper.Get(key))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		*commands[n] = c
+	}
+	if config.Empty() {
+		return nil, nil
+	}
+
+	// The log goes next to the database, as backups do
+	log, err := os.OpenFile(viper.GetString("db")+".hooks.log",
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	hookRunner = hooks.New(config, log, func(err error) { warn(err) })
+	return hookRunner, nil
+}
+
+// Wait for the hooks started by the command before it exits
+func waitHooks() {
+	if hookRunner != nil {
+		hookRunner.Wait()
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/xor111xor/pomodoro-go/internal/importer"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Import history from CSV, Timewarrior or Toggl",
+	Long: `Add intervals read from FILE, or stdin when FILE is -, to the
+database.
+
+Formats:
+  csv          columns as written by export, other columns are
+               mapped with --map, e.g. --map start=Begin,task=Note
+  timewarrior  JSON written by timew export
+  toggl        CSV detailed report of Toggl Track
+
+Entries starting on the same second as an interval of the same
+category are duplicates and skipped, so an import can be repeated.
+Entries overlapping other intervals are skipped unless
+--allow-overlap is given. With --dry-run nothing is written.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return err
+		}
+		mapFlag, err := cmd.Flags().GetString("map")
+		if err != nil {
+			return err
+		}
+		layout, err := cmd.Flags().GetString("time-format")
+		if err != nil {
+			return err
+		}
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			return err
+		}
+		allowOverlap, err := cmd.Flags().GetBool("allow-overlap")
+		if err != nil {
+			return err
+		}
+		verbose, err := cmd.Flags().GetBool("verbose")
+		if err != nil {
+			return err
+		}
+
+		config, err := getConfig()
+		if err != nil {
+			return err
+		}
+		mapping, err := importer.ParseMapping(mapFlag)
+		if err != nil {
+			return err
+		}
+		src, err := importer.NewSource(format, importer.CSVOptions{
+			Mapping:    mapping,
+			TimeLayout: layout,
+			Location:   config.Location,
+		})
+		if err != nil {
+			return err
+		}
+
+		in := cmd.InOrStdin()
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+
+		opts := importer.Options{DryRun: dryRun, AllowOverlap: allowOverlap}
+		return importAction(cmd.Context(), cmd.OutOrStdout(), config, src, in, opts, verbose)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().StringP("format", "f", importer.FormatCSV, "Input format: csv, timewarrior or toggl")
+	importCmd.Flags().String("map", "", "CSV columns of start, end, actual, planned, category, state, task, tags and project as field=column")
+	importCmd.Flags().String("time-format", "", "Go layout of CSV times (default RFC 3339)")
+	importCmd.Flags().BoolP("dry-run", "n", false, "Show what would be imported without writing")
+	importCmd.Flags().Bool("allow-overlap", false, "Import entries overlapping other intervals")
+	importCmd.Flags().BoolP("verbose", "v", false, "List skipped entries")
+}
+
+func importAction(ctx context.Context, out io.Writer, config *models.IntervalConfig,
+	src importer.Source, in io.Reader, opts importer.Options, verbose bool) error {
+	res, err := importer.Import(ctx, config.Repo, src, in, opts)
+	if err != nil {
+		return err
+	}
+
+	if verbose {
+		for _, i := range res.Duplicates {
+			fmt.Fprintf(out, "duplicate: %s\n", describe(i, config))
+		}
+		for _, i := range res.Overlaps {
+			fmt.Fprintf(out, "overlap: %s\n", describe(i, config))
+		}
+	}
+
+	action := "Imported"
+	if opts.DryRun {
+		action = "Would import"
+	}
+	_, err = fmt.Fprintf(out, "%s %d intervals, %d duplicates, %d overlapping\n",
+		action, len(res.Imported), len(res.Duplicates), len(res.Overlaps))
+	return err
+}
+
+func describe(i models.Interval, config *models.IntervalConfig) string {
+	s := fmt.Sprintf("%s %s %s", i.TimeStart.In(config.Location).Format("2006-01-02 15:04"),
+		i.Category, i.TimeActual)
+	if i.Task != "" {
+		s += " " + i.Task
+	}
+	return s
+}
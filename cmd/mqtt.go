@@ -0,0 +1,85 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/mqtt"
+)
+
+func init() {
+	viper.SetDefault("mqtt.broker", "")
+	viper.SetDefault("mqtt.client-id", "")
+	viper.SetDefault("mqtt.username", "")
+	viper.SetDefault("mqtt.password", "")
+	viper.SetDefault("mqtt.topic", mqtt.DefaultTopic())
+	viper.SetDefault("mqtt.qos", 0)
+	viper.SetDefault("mqtt.ticks", false)
+	viper.SetDefault("mqtt.ca", "")
+	viper.SetDefault("mqtt.cert", "")
+	viper.SetDefault("mqtt.key", "")
+	viper.SetDefault("mqtt.insecure", false)
+}
+
+func mqttConfig() mqtt.Config {
+	return mqtt.Config{
+		Broker:   viper.GetString("mqtt.broker"),
+		ClientID: viper.GetString("mqtt.client-id"),
+		Username: viper.GetString("mqtt.username"),
+		Password: viper.GetString("mqtt.password"),
+		Topic:    viper.GetString("mqtt.topic"),
+		QoS:      byte(viper.GetInt("mqtt.qos")),
+		Ticks:    viper.GetBool("mqtt.ticks"),
+		CAFile:   viper.GetString("mqtt.ca"),
+		CertFile: viper.GetString("mqtt.cert"),
+		KeyFile:  viper.GetString("mqtt.key"),
+		Insecure: viper.GetBool("mqtt.insecure"),
+	}
+}
+
+// An empty broker disables MQTT
+func checkBroker(v any) error {
+	s, err := cast.ToStringE(v)
+	if err != nil || s == "" {
+		return err
+	}
+	return mqtt.Config{Broker: s}.Validate()
+}
+
+func checkQoS(v any) error {
+	n, err := cast.ToIntE(v)
+	if err == nil && (n < 0 || n > 2) {
+		err = fmt.Errorf("want 0, 1 or 2, got %d", n)
+	}
+	return err
+}
+
+// Bridge timer to the configured broker until ctx is done, for commands
+// running the timer. The returned function waits for the bridge to
+// announce it is offline.
+func runMQTT(ctx context.Context, out io.Writer, timer daemon.Timer) (func(), error) {
+	config := mqttConfig()
+	if config.Broker == "" {
+		return func() {}, nil
+	}
+	b, err := mqtt.New(config, timer)
+	if err != nil {
+		return nil, fmt.Errorf("mqtt: %w", err)
+	}
+	fmt.Fprintln(out, "Publishing to", config.Broker)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := b.Run(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintln(out, "mqtt:", err)
+		}
+	}()
+	return func() { <-done }, nil
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/notify"
+)
+
+// Notifier of finished intervals, nil unless enabled
+var notifier *notify.Notifier
+
+func init() {
+	viper.SetDefault("notify.enabled", false)
+	viper.SetDefault("notify.pomodoro.title", notify.DefaultPomodoro.Title)
+	viper.SetDefault("notify.pomodoro.body", notify.DefaultPomodoro.Body)
+	viper.SetDefault("notify.break.title", notify.DefaultBreak.Title)
+	viper.SetDefault("notify.break.body", notify.DefaultBreak.Body)
+	viper.SetDefault("notify.snooze", notify.DefaultSnooze)
+}
+
+// Titles and bodies are templates
+func checkNotifyTemplate(v any) error {
+	s, err := cast.ToStringE(v)
+	if err != nil {
+		return err
+	}
+	return notify.Message{Title: s}.Validate()
+}
+
+// Return notifier of the intervals of config, nil unless enabled
+func getNotifier(config *models.IntervalConfig) (*notify.Notifier, error) {
+	if notifier != nil || !viper.GetBool("notify.enabled") {
+		return notifier, nil
+	}
+	var err error
+	notifier, err = notify.New(notify.Config{
+		Pomodoro: notify.Message{
+			Title: viper.GetString("notify.pomodoro.title"),
+			Body:  viper.GetString("notify.pomodoro.body"),
+		},
+		Break: notify.Message{
+			Title: viper.GetString("notify.break.title"),
+			Body:  viper.GetString("notify.break.body"),
+		},
+		Snooze: viper.GetDuration("notify.snooze"),
+	}, config, func(err error) { warn(err) })
+	return notifier, err
+}
+
+// Offer the start and snooze actions in notifications, for commands
+// able to start intervals until they exit
+func notifyStart(start func(context.Context) error) {
+	if notifier != nil {
+		notifier.OnStart(start)
+	}
+}
+
+// Show the notifications of the command before it exits
+func closeNotifier() {
+	if notifier != nil {
+		notifier.Wait()
+		notifier.Close()
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// pauseCmd represents the pause command
+var pauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Pause the running interval",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := getConfig()
+		if err != nil {
+			return err
+		}
+		if c := daemonClient(); c != nil {
+			defer c.Close()
+			return pauseDaemon(cmd.Context(), cmd.OutOrStdout(), c)
+		}
+		return pauseAction(cmd.Context(), cmd.OutOrStdout(), config)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pauseCmd)
+}
+
+func pauseAction(ctx context.Context, out io.Writer, config *models.IntervalConfig) error {
+	i, err := config.Repo.Last(ctx)
+	if err != nil {
+		return err
+	}
+	if err := i.Pause(ctx, config); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s paused, %s left\n",
+		i.Category, i.TimePlanning-i.TimeActual)
+	return err
+}
+
+func pauseDaemon(ctx context.Context, out io.Writer, c *daemon.Client) error {
+	s, err := c.Do(ctx, daemon.CmdPause)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s paused, %s left\n", s.Category, s.Remaining)
+	return err
+}
@@ -0,0 +1,99 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Per-directory config file, found in the working directory or its
+// parents like .git
+const projectFileName = ".pomodoro.yaml"
+
+// Keys a project file may set. Others could move the database or run
+// commands for whoever works in a cloned repository.
+var projectKeys = map[string]bool{
+	"project": true,
+	"task":    true,
+	"tags":    true,
+	"pomo":    true,
+	"long":    true,
+	"short":   true,
+}
+
+var (
+	// Project file in use, empty for none
+	projectFile string
+	// Values taken from the project file
+	projectSettings map[string]any
+)
+
+// Return the nearest project file in dir or its parents, empty for none
+func findProjectFile(dir string) string {
+	for {
+		path := filepath.Join(dir, projectFileName)
+		if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Layer the nearest project file over the config file. The project is
+// named after the directory of the file unless the file names it.
+func mergeProjectConfig() error {
+	// Without a working directory there is no project
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	path := findProjectFile(wd)
+	if path == "" {
+		return nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("project file: %w", err)
+	}
+	keys := v.AllKeys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !projectKeys[key] {
+			return fmt.Errorf("project file %s: %q can not be set here, only %s",
+				path, key, strings.Join(projectKeyNames(), ", "))
+		}
+	}
+
+	settings := v.AllSettings()
+	if _, ok := settings["project"]; !ok {
+		settings["project"] = filepath.Base(filepath.Dir(path))
+	}
+	if err := viper.MergeConfigMap(settings); err != nil {
+		return fmt.Errorf("project file: %w", err)
+	}
+	projectFile, projectSettings = path, settings
+	return nil
+}
+
+func projectKeyNames() []string {
+	names := []string{}
+	for key := range projectKeys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	return names
+}
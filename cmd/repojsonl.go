@@ -0,0 +1,17 @@
+//go:build jsonl
+
+package cmd
+
+import (
+	"github.com/spf13/viper"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/repository"
+)
+
+func getRepo() (models.Repository, error) {
+	repo, err := repository.NewJSONLRepo(viper.GetString("db"))
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
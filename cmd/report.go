@@ -0,0 +1,224 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xor111xor/pomodoro-go/internal/git"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Print summaries of past intervals",
+	Long: `Print a table of completed pomodoros, breaks and focus time
+for a range of days, grouped by day, week, month, category, tag or
+project.
+
+The range is either --from and --to, both dates included, or the
+days counted back from today with --last, e.g. 30d or 4w. Days start
+at --day-start in --timezone, as in the TUI.
+
+With --commits the git commits made during each pomodoro follow,
+grouped by task, for pomodoros recorded with git.enabled.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fromFlag, err := cmd.Flags().GetString("from")
+		if err != nil {
+			return err
+		}
+		toFlag, err := cmd.Flags().GetString("to")
+		if err != nil {
+			return err
+		}
+		last, err := cmd.Flags().GetString("last")
+		if err != nil {
+			return err
+		}
+		group, err := cmd.Flags().GetString("group")
+		if err != nil {
+			return err
+		}
+		commits, err := cmd.Flags().GetBool("commits")
+		if err != nil {
+			return err
+		}
+
+		config, err := getConfig()
+		if err != nil {
+			return err
+		}
+		from, to, err := reportRange(config, time.Now(), fromFlag, toFlag, last)
+		if err != nil {
+			return err
+		}
+		if err := reportAction(cmd.Context(), cmd.OutOrStdout(), config, from, to, group); err != nil {
+			return err
+		}
+		if commits {
+			return reportCommits(cmd.Context(), cmd.OutOrStdout(), config, from, to)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+
+	reportCmd.Flags().String("from", "", "First day of the report, YYYY-MM-DD")
+	reportCmd.Flags().String("to", "", "Last day of the report, YYYY-MM-DD (default today)")
+	reportCmd.Flags().String("last", "7d", "Report the last days or weeks, e.g. 30d or 4w")
+	reportCmd.Flags().StringP("group", "g", models.GroupDay, "Group by day, week, month, category, tag or project")
+	reportCmd.Flags().Bool("commits", false, "List the git commits made during each pomodoro by task")
+}
+
+// Parse number of days given as Nd or Nw
+func parseLast(s string) (int, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid --last %q", s)
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid --last %q", s)
+	}
+	switch strings.ToLower(s[len(s)-1:]) {
+	case "d":
+		return n, nil
+	case "w":
+		return n * 7, nil
+	}
+	return 0, fmt.Errorf("invalid --last %q", s)
+}
+
+// Resolve report flags to the first and last day
+func reportRange(config *models.IntervalConfig, now time.Time,
+	from, to, last string) (time.Time, time.Time, error) {
+	end := config.Day(now)
+	if to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, end.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --to: %w", err)
+		}
+		end = t
+	}
+
+	if from != "" {
+		start, err := time.ParseInLocation("2006-01-02", from, end.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --from: %w", err)
+		}
+		if start.After(end) {
+			return time.Time{}, time.Time{}, fmt.Errorf("--from %s is after --to %s", from, end.Format("2006-01-02"))
+		}
+		return start, end, nil
+	}
+
+	days, err := parseLast(last)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return end.AddDate(0, 0, 1-days), end, nil
+}
+
+// Format duration as hours and minutes
+func hoursMinutes(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+func reportAction(ctx context.Context, out io.Writer, config *models.IntervalConfig,
+	from, to time.Time, group string) error {
+	r, err := models.NewReport(ctx, config, from, to, group)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Report %s - %s, %d days\n\n",
+		r.From.Format("2006-01-02"), r.To.Format("2006-01-02"), r.Days)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tPOMODOROS\tCANCELED\tBREAKS\tFOCUS\tBREAK\t\n", strings.ToUpper(group))
+	for _, row := range append(r.Rows, r.Total) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\t\n", row.Key, row.Pomodoros,
+			row.Canceled, row.Breaks, hoursMinutes(row.Focus), hoursMinutes(row.Break))
+	}
+	fmt.Fprintf(w, "Average/day\t%.1f\t\t\t%s\t\t\n", r.PomodorosPerDay(), hoursMinutes(r.FocusPerDay()))
+	return w.Flush()
+}
+
+// Print the commits made during the pomodoros of the days from through
+// to, grouped by task. Working trees which can not be read any more are
+// warned about and skipped.
+func reportCommits(ctx context.Context, out io.Writer, config *models.IntervalConfig,
+	from, to time.Time) error {
+	start, _ := config.DateBounds(from)
+	_, end := config.DateBounds(to)
+
+	pomodoros := map[string][]models.Interval{}
+	tasks := []string{}
+	err := config.Repo.Range(ctx, start, end, func(i models.Interval) error {
+		if i.Category != models.PomodoCategory || i.HeadEnd == "" || i.HeadEnd == i.HeadStart {
+			return nil
+		}
+		task := i.Task
+		if task == "" {
+			task = models.NoTag
+		}
+		if _, ok := pomodoros[task]; !ok {
+			tasks = append(tasks, task)
+		}
+		pomodoros[task] = append(pomodoros[task], i)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(tasks)
+
+	fmt.Fprintln(out, "\nCommits by task")
+	if len(tasks) == 0 {
+		fmt.Fprintln(out, "\nNo commits recorded")
+	}
+	repos := map[string]*git.Repo{}
+	for _, task := range tasks {
+		fmt.Fprintf(out, "\n%s\n", task)
+		for _, i := range pomodoros[task] {
+			r, ok := repos[i.GitDir]
+			if !ok {
+				if r, err = git.Open(i.GitDir); err != nil {
+					warn(err)
+				}
+				repos[i.GitDir] = r
+			}
+			if r == nil {
+				continue
+			}
+			commits, err := r.Log(i.HeadStart, i.HeadEnd, i.TimeStart)
+			if err != nil {
+				warn(err)
+				continue
+			}
+
+			fmt.Fprintf(out, "  %s  %s\n", i.TimeStart.In(config.Location).Format("2006-01-02 15:04"),
+				filepath.Base(i.GitDir))
+			// Oldest first
+			for n := len(commits) - 1; n >= 0; n-- {
+				fmt.Fprintf(out, "    %.7s %s\n", commits[n].Hash, commits[n].Subject)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore file",
+	Short: "Replace the database with a backup",
+	Long: `Replace the database with a backup taken by the backup command.
+The backup is verified before anything is overwritten.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		return restoreAction(cmd.Context(), cmd.OutOrStdout(), repo, args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func restoreAction(ctx context.Context, out io.Writer, repo models.Repository, src string) error {
+	b, err := getBackuper(repo)
+	if err != nil {
+		return err
+	}
+	if err := b.Restore(ctx, src); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, "Database restored from", src)
+	return err
+}
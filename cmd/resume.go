@@ -0,0 +1,66 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// resumeCmd represents the resume command
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Continue the paused interval",
+	Long: `Continue the paused interval and wait until it is finished,
+like the start command but without starting a new interval.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		background, err := cmd.Flags().GetBool("background")
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if c := daemonClient(); c != nil {
+			defer c.Close()
+			return daemonRun(ctx, cmd.OutOrStdout(), c, daemon.CmdResume, background)
+		}
+
+		if background && os.Getenv(detachedEnv) == "" {
+			return detach(cmd.OutOrStdout())
+		}
+
+		config, err := getConfig()
+		if err != nil {
+			return err
+		}
+		return resumeAction(ctx, cmd.OutOrStdout(), config)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resumeCmd)
+
+	resumeCmd.Flags().BoolP("background", "b", false, "Run the interval in a background process")
+}
+
+func resumeAction(ctx context.Context, out io.Writer, config *models.IntervalConfig) error {
+	i, err := config.Repo.Last(ctx)
+	if err != nil {
+		return err
+	}
+	if i.State != models.StatePaused {
+		return fmt.Errorf("%w: %s %s", models.ErrIntervalNotPaused, i.Category, models.StateName(i.State))
+	}
+	return runInterval(ctx, out, config, i)
+}
@@ -4,38 +4,52 @@ Copyright © 2023 xor111xor
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/xor111xor/pomodoro-go/internal/app"
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
 	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/osc"
 )
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "pomodoro-go",
 	Short: "Interactive pomodoro timer",
+	// Commands need a readable and valid config, config subcommands
+	// report the problems themselves
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if cfgErr != nil {
+			return cfgErr
+		}
+		return errors.Join(checkConfig()...)
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		waitHooks()
+		waitTaskwarrior()
+		closeNotifier()
+		flushWebhooks(cmd.Context())
+	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	RunE: func(cmd *cobra.Command, args []string) error {
-		repo, err := getRepo()
+		config, err := getConfig()
 		if err != nil {
 			return err
 		}
-		config, err := models.NewConfig(
-			repo,
-			viper.GetDuration("pomo"),
-			viper.GetDuration("long"),
-			viper.GetDuration("short"),
-		)
-		if err != nil {
+		if err := autoBackup(cmd.Context(), config.Repo); err != nil {
 			return err
 		}
-		return rootAction(os.Stdout, config)
+		return rootAction(cmd.Context(), os.Stdout, config)
 
 	},
 }
@@ -49,7 +63,14 @@ func Execute() {
 	}
 }
 
-var cfgFile string
+var (
+	cfgFile string
+	// Error reading the config file, missing default file is fine
+	cfgErr error
+	// Reports failures of background work such as hooks, the dashboard
+	// shows them itself
+	warn = func(err error) { fmt.Fprintln(os.Stderr, err) }
+)
 
 func init() {
 	// Here you will define your flags and configuration settings.
@@ -57,19 +78,29 @@ func init() {
 	// will be global for your application.
 	cobra.OnInitialize(initConfig)
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.config/pomodoro-go/pomodoro-go.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $XDG_CONFIG_HOME/pomodoro-go/pomodoro-go.yaml)")
 
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
-	rootCmd.Flags().StringP("db", "d", "pomo.db", "Database for pomo")
-	rootCmd.Flags().DurationP("pomo", "p", 25*time.Minute, "Pomodoro duration")
-	rootCmd.Flags().DurationP("long", "l", 15*time.Minute, "Long break duration")
-	rootCmd.Flags().DurationP("short", "s", 5*time.Minute, "Short break duration")
+	rootCmd.PersistentFlags().StringP("db", "d", "pomo.db", "Database for pomo")
+	rootCmd.PersistentFlags().String("timezone", "", "Timezone for daily summaries (default is local)")
+	rootCmd.PersistentFlags().String("day-start", "00:00", "Time of day when a new day starts for summaries")
+	rootCmd.PersistentFlags().DurationP("pomo", "p", 25*time.Minute, "Pomodoro duration")
+	rootCmd.PersistentFlags().DurationP("long", "l", 15*time.Minute, "Long break duration")
+	rootCmd.PersistentFlags().DurationP("short", "s", 5*time.Minute, "Short break duration")
+	rootCmd.PersistentFlags().String("socket", "", "Socket of the daemon (default is $XDG_RUNTIME_DIR/pomodoro-go.sock)")
+	rootCmd.PersistentFlags().String("task", "", "Task recorded on new pomodoros")
+	rootCmd.PersistentFlags().StringSlice("tags", nil, "Comma separated tags recorded on new pomodoros")
+	rootCmd.PersistentFlags().String("project", "", "Project recorded on new intervals (default from "+projectFileName+")")
 
-	viper.BindPFlag("db", rootCmd.Flags().Lookup("db"))
-	viper.BindPFlag("pomo", rootCmd.Flags().Lookup("pomo"))
-	viper.BindPFlag("long", rootCmd.Flags().Lookup("long"))
-	viper.BindPFlag("short", rootCmd.Flags().Lookup("short"))
+	bindFlag("db", rootCmd.PersistentFlags().Lookup("db"))
+	bindFlag("timezone", rootCmd.PersistentFlags().Lookup("timezone"))
+	bindFlag("day-start", rootCmd.PersistentFlags().Lookup("day-start"))
+	bindFlag("pomo", rootCmd.PersistentFlags().Lookup("pomo"))
+	bindFlag("long", rootCmd.PersistentFlags().Lookup("long"))
+	bindFlag("short", rootCmd.PersistentFlags().Lookup("short"))
+	bindFlag("socket", rootCmd.PersistentFlags().Lookup("socket"))
+	bindFlag("task", rootCmd.PersistentFlags().Lookup("task"))
+	bindFlag("tags", rootCmd.PersistentFlags().Lookup("tags"))
+	bindFlag("project", rootCmd.PersistentFlags().Lookup("project"))
 }
 
 func initConfig() {
@@ -79,20 +110,124 @@ func initConfig() {
 	} else {
 		viper.SetConfigType("yaml")
 		viper.SetConfigName("pomodoro-go")
-		viper.AddConfigPath("$HOME/.config/pomodoro-go/")
+		if dir, err := configDir(); err == nil {
+			viper.AddConfigPath(dir)
+		}
 	}
 
+	// backup.keep is read from BACKUP_KEEP, day-start from DAY_START
+	viper.SetEnvKeyReplacer(envKeyReplacer)
 	viper.AutomaticEnv()
 
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+	// Stdout is kept clean for machine-readable output
+	err := viper.ReadInConfig()
+	if err == nil {
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+	} else if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+		cfgErr = fmt.Errorf("config file: %w", err)
+		return
+	}
+
+	if err := mergeProjectConfig(); err != nil {
+		cfgErr = err
+		return
+	}
+	if projectFile != "" {
+		fmt.Fprintln(os.Stderr, "Using project file:", projectFile)
+	}
+}
+
+var envKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+// Return directory of the default config file
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(dir, "pomodoro-go"), nil
 }
 
-func rootAction(out io.Writer, config *models.IntervalConfig) error {
-	a, err := app.New(config)
+// Open repository and build interval config from flags and config file
+func getConfig() (*models.IntervalConfig, error) {
+	repo, err := getRepo()
+	if err != nil {
+		return nil, err
+	}
+	config, err := models.NewConfig(
+		repo,
+		viper.GetDuration("pomo"),
+		viper.GetDuration("long"),
+		viper.GetDuration("short"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if tz := viper.GetString("timezone"); tz != "" {
+		if config.Location, err = time.LoadLocation(tz); err != nil {
+			return nil, err
+		}
+	}
+	if config.DayStart, err = models.ParseDayStart(viper.GetString("day-start")); err != nil {
+		return nil, err
+	}
+	config.Task = viper.GetString("task")
+	config.Tags = viper.GetStringSlice("tags")
+	config.Project = viper.GetString("project")
+	config.Warn = func(err error) { warn(err) }
+	if err := setCalendar(config); err != nil {
+		return nil, err
+	}
+	if err := setGit(config); err != nil {
+		return nil, err
+	}
+
+	hooks, err := getHooks()
+	if err != nil {
+		return nil, err
+	}
+	if hooks != nil {
+		config.Observers = append(config.Observers, hooks)
+	}
+	webhooks, err := getWebhooks()
+	if err != nil {
+		return nil, err
+	}
+	if webhooks != nil {
+		config.Observers = append(config.Observers, webhooks)
+	}
+	notifier, err := getNotifier(config)
+	if err != nil {
+		return nil, err
+	}
+	if notifier != nil {
+		config.Observers = append(config.Observers, notifier)
+	}
+	setTaskwarrior(config)
+	if err := setTodoTxt(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func rootAction(ctx context.Context, out io.Writer, config *models.IntervalConfig) error {
+	c := daemonClient()
+	if c != nil {
+		defer c.Close()
+		c.Origin = func() daemon.Origin { return origin(config.Picked) }
+	}
+
+	a, err := app.New(config, c, osc.New(terminalConfig(), out))
 	if err != nil {
 		return err
 	}
+	// The terminal belongs to the dashboard now
+	warn = a.Warn
+	runWebhooks(ctx)
+	notifyStart(func(context.Context) error {
+		a.Start()
+		return nil
+	})
 	return a.Run()
 }
@@ -0,0 +1,156 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/xor111xor/pomodoro-go/internal/api"
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/metrics"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a local HTTP API with server-sent events and metrics",
+	Long: `Serve REST endpoints to control the timer and query history and
+reports, plus a stream of server-sent events, for dashboards and editor
+plugins, and Prometheus metrics at /metrics. The endpoints are
+documented in the internal/api package.
+
+The API only listens on localhost and refuses requests for other hosts
+or, changing the timer, from web pages of other sites. With a token
+every request needs an "Authorization: Bearer <token>" header.
+
+A running daemon keeps the timer, otherwise serve runs it and listens
+on the daemon socket too, so the other commands control the same timer.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := getConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return serveAction(ctx, os.Stderr, config,
+			viper.GetString("serve.addr"), viper.GetString("serve.token"))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().String("addr", "localhost:8425", "Address to listen on, host must be a loopback address")
+	serveCmd.Flags().String("token", "", "Bearer token required from clients")
+
+	bindFlag("serve.addr", serveCmd.Flags().Lookup("addr"))
+	bindFlag("serve.token", serveCmd.Flags().Lookup("token"))
+}
+
+// Refuse addresses reachable from other hosts
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("serve only listens on localhost, not %q", host)
+}
+
+func serveAction(ctx context.Context, out io.Writer, config *models.IntervalConfig, addr, token string) error {
+	if err := checkLoopback(addr); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var timer daemon.Timer
+	if c := daemonClient(); c != nil {
+		defer c.Close()
+		timer = c
+		fmt.Fprintln(out, "Using daemon on", socketPath())
+	} else {
+		if err := autoBackup(ctx, config.Repo); err != nil {
+			return err
+		}
+		path := socketPath()
+		l, err := daemon.Listen(path)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(path)
+
+		s := daemon.NewServer(config)
+		served := make(chan error, 1)
+		go func() { served <- s.Serve(ctx, l) }()
+		defer func() {
+			cancel()
+			<-served
+		}()
+		timer = s
+		fmt.Fprintln(out, "Listening on", path)
+		runWebhooks(ctx)
+		notifyStart(func(ctx context.Context) error {
+			_, err := s.Do(ctx, daemon.CmdStart)
+			return err
+		})
+		waitMQTT, err := runMQTT(ctx, out, s)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			cancel()
+			waitMQTT()
+		}()
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	m := metrics.New(config, timer)
+	go func() {
+		if err := m.Run(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintln(out, "metrics:", err)
+		}
+	}()
+	srv := &http.Server{
+		Handler:           api.NewHandler(config, timer, m, token),
+		ReadHeaderTimeout: 10 * time.Second,
+		// Event streams end with ctx
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	stopServer := context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	})
+	defer stopServer()
+
+	fmt.Fprintf(out, "Serving http://%s/api/v1\n", l.Addr())
+	if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// skipCmd represents the skip command
+var skipCmd = &cobra.Command{
+	Use:   "skip",
+	Short: "Finish the current or upcoming interval early",
+	Long: `Mark the current interval as skipped, or the upcoming one when
+nothing is in progress, so the sequence of pomodoros and breaks moves on.
+Skipped pomodoros are not counted as done.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := getConfig()
+		if err != nil {
+			return err
+		}
+		if c := daemonClient(); c != nil {
+			defer c.Close()
+			return skipDaemon(cmd.Context(), cmd.OutOrStdout(), config, c)
+		}
+		return skipAction(cmd.Context(), cmd.OutOrStdout(), config)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(skipCmd)
+}
+
+func skipAction(ctx context.Context, out io.Writer, config *models.IntervalConfig) error {
+	i, err := models.GetInterval(ctx, config)
+	if err != nil {
+		return err
+	}
+	if err := i.Skip(ctx, config); err != nil {
+		return err
+	}
+
+	next, err := models.NextCategory(ctx, config.Repo)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s skipped, next is %s\n", i.Category, next)
+	return err
+}
+
+func skipDaemon(ctx context.Context, out io.Writer, config *models.IntervalConfig, c *daemon.Client) error {
+	s, err := c.Do(ctx, daemon.CmdSkip)
+	if err != nil {
+		return err
+	}
+
+	next, err := models.NextCategory(ctx, config.Repo)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s skipped, next is %s\n", s.Category, next)
+	return err
+}
@@ -0,0 +1,122 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Set for the process running a detached interval
+const detachedEnv = "POMODORO_GO_DETACHED"
+
+// startCmd represents the start command
+var startCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the next interval without the dashboard",
+	Long: `Start the next interval, or continue the current one, and wait
+until it is finished. The interval is shared with the dashboard and the
+other commands, so it can be paused or stopped from anywhere.
+Interrupting the command cancels the interval, unless a daemon is
+running: then the daemon owns the interval and interrupting only stops
+waiting.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		background, err := cmd.Flags().GetBool("background")
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if c := daemonClient(); c != nil {
+			defer c.Close()
+			return daemonRun(ctx, cmd.OutOrStdout(), c, daemon.CmdStart, background)
+		}
+
+		if background && os.Getenv(detachedEnv) == "" {
+			return detach(cmd.OutOrStdout())
+		}
+
+		config, err := getConfig()
+		if err != nil {
+			return err
+		}
+		return startAction(ctx, cmd.OutOrStdout(), config)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(startCmd)
+
+	startCmd.Flags().BoolP("background", "b", false, "Run the interval in a background process")
+}
+
+// Run the same command again in a new session, detached from the terminal
+func detach(out io.Writer) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), detachedEnv+"=1")
+	cmd.SysProcAttr = detachAttr()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "Running in background, pid", cmd.Process.Pid)
+	return cmd.Process.Release()
+}
+
+func startAction(ctx context.Context, out io.Writer, config *models.IntervalConfig) error {
+	i, err := models.GetInterval(ctx, config)
+	if err != nil {
+		return err
+	}
+	return runInterval(ctx, out, config, i)
+}
+
+// Tick the interval until it is finished, paused or stopped
+func runInterval(ctx context.Context, out io.Writer, config *models.IntervalConfig, i models.Interval) error {
+	if i.State == models.StateRunning {
+		fmt.Fprintf(out, "%s already running, %s left\n",
+			i.Category, i.TimePlanning-i.TimeActual)
+		return nil
+	}
+
+	start := func(i models.Interval) {
+		fmt.Fprintf(out, "%s started, %s left\n",
+			i.Category, i.TimePlanning-i.TimeActual)
+	}
+	periodic := func(models.Interval) {}
+	end := func(i models.Interval) {
+		fmt.Fprintf(out, "%s done\n", i.Category)
+	}
+
+	runWebhooks(ctx)
+	if err := i.Start(ctx, config, start, periodic, end); err != nil {
+		return err
+	}
+
+	// Paused or stopped elsewhere
+	i, err := config.Repo.ByID(context.WithoutCancel(ctx), i.ID)
+	if err != nil {
+		return err
+	}
+	if i.State != models.StateDone {
+		fmt.Fprintf(out, "%s %s\n", i.Category, models.StateName(i.State))
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Print the current interval for prompts and status bars",
+	Long: `Print the current interval, its remaining time and the number
+of pomodoros completed today.
+
+Formats:
+  plain   single line of text
+  json    JSON object
+  i3bar   i3bar block, the full i3bar protocol with --follow
+  waybar  waybar custom module JSON
+
+A Go template given with --template receives the fields of the json
+format, e.g. --template '{{.Category}} {{.Clock}}'.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return err
+		}
+		tmpl, err := cmd.Flags().GetString("template")
+		if err != nil {
+			return err
+		}
+		follow, err := cmd.Flags().GetBool("follow")
+		if err != nil {
+			return err
+		}
+		every, err := cmd.Flags().GetDuration("interval")
+		if err != nil {
+			return err
+		}
+
+		p, err := newStatusPrinter(format, tmpl)
+		if err != nil {
+			return err
+		}
+		config, err := getConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if c := daemonClient(); c != nil {
+			defer c.Close()
+			return statusDaemon(ctx, cmd.OutOrStdout(), c, p, follow)
+		}
+		return statusAction(ctx, cmd.OutOrStdout(), config, p, follow, every)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().StringP("format", "f", "plain", "Output format: plain, json, i3bar or waybar")
+	statusCmd.Flags().StringP("template", "t", "", "Go template for the output")
+	statusCmd.Flags().Bool("follow", false, "Keep printing the status")
+	statusCmd.Flags().Duration("interval", time.Second, "Update interval with --follow")
+}
+
+// Status as exposed to output formats and templates
+type statusView struct {
+	ID               int64  `json:"id"`
+	Category         string `json:"category"`
+	State            string `json:"state"`
+	Active           bool   `json:"active"`
+	PlannedSeconds   int64  `json:"planned_seconds"`
+	RemainingSeconds int64  `json:"remaining_seconds"`
+	Clock            string `json:"clock"`
+	Today            int    `json:"today"`
+}
+
+func newStatusView(s models.Status) statusView {
+	return statusView{
+		ID:               s.ID,
+		Category:         s.Category,
+		State:            models.StateName(s.State),
+		Active:           s.Active(),
+		PlannedSeconds:   int64(s.Planned.Seconds()),
+		RemainingSeconds: int64(s.Remaining.Seconds()),
+		Clock:            clock(s.Remaining),
+		Today:            s.Today,
+	}
+}
+
+// Format duration as m:ss or h:mm:ss
+func clock(d time.Duration) string {
+	d = d.Round(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+func (v statusView) String() string {
+	if !v.Active {
+		return fmt.Sprintf("Idle (%d today)", v.Today)
+	}
+	if v.State == models.StateName(models.StatePaused) {
+		return fmt.Sprintf("%s paused %s (%d today)", v.Category, v.Clock, v.Today)
+	}
+	return fmt.Sprintf("%s %s (%d today)", v.Category, v.Clock, v.Today)
+}
+
+func (v statusView) color() string {
+	switch {
+	case !v.Active:
+		return "#888888"
+	case v.State == models.StateName(models.StatePaused):
+		return "#F1FA8C"
+	case v.Category == models.PomodoCategory:
+		return "#FF5555"
+	default:
+		return "#50FA7B"
+	}
+}
+
+type statusPrinter struct {
+	format string
+	tmpl   *template.Template
+	// Number of statuses printed, for the i3bar stream
+	count int
+}
+
+func newStatusPrinter(format, tmpl string) (*statusPrinter, error) {
+	p := &statusPrinter{format: format}
+	if tmpl != "" {
+		t, err := template.New("status").Parse(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		p.format = "template"
+		p.tmpl = t
+	}
+
+	switch p.format {
+	case "plain", "json", "i3bar", "waybar", "template":
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unknown status format %q", format)
+	}
+}
+
+func (p *statusPrinter) print(out io.Writer, v statusView, follow bool) error {
+	defer func() { p.count++ }()
+
+	switch p.format {
+	case "json":
+		return json.NewEncoder(out).Encode(v)
+	case "template":
+		if err := p.tmpl.Execute(out, v); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(out)
+		return err
+	case "waybar":
+		percentage := 0
+		if v.PlannedSeconds > 0 {
+			percentage = int(100 * (v.PlannedSeconds - v.RemainingSeconds) / v.PlannedSeconds)
+		}
+		return json.NewEncoder(out).Encode(struct {
+			Text       string `json:"text"`
+			Alt        string `json:"alt"`
+			Tooltip    string `json:"tooltip"`
+			Class      string `json:"class"`
+			Percentage int    `json:"percentage"`
+		}{
+			Text:       v.String(),
+			Alt:        v.Category,
+			Tooltip:    fmt.Sprintf("%s %s, %d pomodoros today", v.Category, v.State, v.Today),
+			Class:      strings.ToLower(v.State),
+			Percentage: percentage,
+		})
+	case "i3bar":
+		block, err := json.Marshal(struct {
+			Name      string `json:"name"`
+			FullText  string `json:"full_text"`
+			ShortText string `json:"short_text"`
+			Color     string `json:"color"`
+		}{
+			Name:      "pomodoro",
+			FullText:  v.String(),
+			ShortText: v.Clock,
+			Color:     v.color(),
+		})
+		if err != nil {
+			return err
+		}
+		if !follow {
+			_, err = fmt.Fprintf(out, "%s\n", block)
+			return err
+		}
+
+		// Header and the start of the endless array come first
+		if p.count == 0 {
+			_, err = fmt.Fprintf(out, "{\"version\":1}\n[\n[%s]\n", block)
+			return err
+		}
+		_, err = fmt.Fprintf(out, ",[%s]\n", block)
+		return err
+	default:
+		_, err := fmt.Fprintln(out, v)
+		return err
+	}
+}
+
+func statusAction(ctx context.Context, out io.Writer, config *models.IntervalConfig,
+	p *statusPrinter, follow bool, every time.Duration) error {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		s, err := models.CurrentStatus(ctx, config, time.Now())
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := p.print(out, newStatusView(s), follow); err != nil {
+			return err
+		}
+		if !follow {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Print status of the daemon, with follow on every event
+func statusDaemon(ctx context.Context, out io.Writer, c *daemon.Client,
+	p *statusPrinter, follow bool) error {
+	if !follow {
+		s, err := c.Do(ctx, daemon.CmdStatus)
+		if err != nil {
+			return err
+		}
+		return p.print(out, newStatusView(s), false)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var err error
+	serr := c.Subscribe(ctx, func(ev daemon.Event) {
+		if err = p.print(out, newStatusView(ev.Status), true); err != nil {
+			cancel()
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
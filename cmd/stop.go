@@ -0,0 +1,59 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// stopCmd represents the stop command
+var stopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Cancel the current interval",
+	Long: `Cancel the current interval. The next interval is a pomodoro,
+just like quitting the dashboard while an interval is running.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := getConfig()
+		if err != nil {
+			return err
+		}
+		if c := daemonClient(); c != nil {
+			defer c.Close()
+			return stopDaemon(cmd.Context(), cmd.OutOrStdout(), c)
+		}
+		return stopAction(cmd.Context(), cmd.OutOrStdout(), config)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stopCmd)
+}
+
+func stopAction(ctx context.Context, out io.Writer, config *models.IntervalConfig) error {
+	i, err := config.Repo.Last(ctx)
+	if err != nil {
+		return err
+	}
+	if err := i.Stop(ctx, config); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s canceled\n", i.Category)
+	return err
+}
+
+func stopDaemon(ctx context.Context, out io.Writer, c *daemon.Client) error {
+	s, err := c.Do(ctx, daemon.CmdStop)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s canceled\n", s.Category)
+	return err
+}
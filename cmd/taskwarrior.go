@@ -0,0 +1,39 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"github.com/spf13/viper"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/taskwarrior"
+)
+
+// Client of Taskwarrior, nil unless enabled
+var taskwarriorClient *taskwarrior.Client
+
+func init() {
+	viper.SetDefault("taskwarrior.enabled", false)
+	viper.SetDefault("taskwarrior.filter", "")
+}
+
+// Offer the pending Taskwarrior tasks to the dashboard and write the
+// progress of their pomodoros back
+func setTaskwarrior(config *models.IntervalConfig) {
+	if !viper.GetBool("taskwarrior.enabled") {
+		return
+	}
+	if taskwarriorClient == nil {
+		taskwarriorClient = taskwarrior.New(viper.GetString("taskwarrior.filter"),
+			config.Picked, func(err error) { warn(err) })
+	}
+	config.Tasks = taskwarriorClient
+	config.Observers = append(config.Observers, taskwarriorClient)
+}
+
+// Wait for the write-backs of the command before it exits
+func waitTaskwarrior() {
+	if taskwarriorClient != nil {
+		taskwarriorClient.Wait()
+	}
+}
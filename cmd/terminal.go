@@ -0,0 +1,26 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"github.com/spf13/viper"
+	"github.com/xor111xor/pomodoro-go/internal/osc"
+)
+
+func init() {
+	viper.SetDefault("terminal.bell", true)
+	viper.SetDefault("terminal.osc9", false)
+	viper.SetDefault("terminal.osc777", false)
+	viper.SetDefault("terminal.title", true)
+}
+
+// Escape sequences enabled for the dashboard's terminal
+func terminalConfig() osc.Config {
+	return osc.Config{
+		Bell:   viper.GetBool("terminal.bell"),
+		OSC9:   viper.GetBool("terminal.osc9"),
+		OSC777: viper.GetBool("terminal.osc777"),
+		Title:  viper.GetBool("terminal.title"),
+	}
+}
@@ -0,0 +1,32 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/viper"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/todotxt"
+)
+
+func init() {
+	viper.SetDefault("todotxt.file", "")
+}
+
+// Offer the open tasks of the configured todo.txt file to the dashboard
+// and count their pomodoros in it
+func setTodoTxt(config *models.IntervalConfig) error {
+	path := viper.GetString("todotxt.file")
+	if path == "" {
+		return nil
+	}
+	if viper.GetBool("taskwarrior.enabled") {
+		return errors.New("taskwarrior and todotxt can not be used together")
+	}
+	file := todotxt.New(path, func(err error) { warn(err) })
+	config.Tasks = file
+	config.Observers = append(config.Observers, file)
+	return nil
+}
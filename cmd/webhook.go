@@ -0,0 +1,82 @@
+/*
+Copyright © 2023 xor111xor
+*/
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+	"github.com/xor111xor/pomodoro-go/internal/webhook"
+)
+
+// Wait on exit for the deliveries of the transitions made by a command
+const webhookFlushTimeout = 10 * time.Second
+
+// Sender of the configured webhooks, nil without any
+var webhooks *webhook.Sender
+
+func init() {
+	viper.SetDefault("webhooks", []any{})
+}
+
+// Decode and check the webhooks config list
+func decodeWebhooks(v any) ([]webhook.Hook, error) {
+	hooks := []webhook.Hook{}
+	if v == nil {
+		return hooks, nil
+	}
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:  mapstructure.StringToTimeDurationHookFunc(),
+		ErrorUnused: true,
+		Result:      &hooks,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := dec.Decode(v); err != nil {
+		return nil, err
+	}
+	for _, h := range hooks {
+		if err := h.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return hooks, nil
+}
+
+// Return sender of the configured webhooks, nil without any
+func getWebhooks() (*webhook.Sender, error) {
+	if webhooks != nil {
+		return webhooks, nil
+	}
+	hooks, err := decodeWebhooks(viper.Get("webhooks"))
+	if err != nil || len(hooks) == 0 {
+		return nil, err
+	}
+
+	// The queue goes next to the database, as backups do
+	dir := viper.GetString("db") + ".webhooks"
+	webhooks, err = webhook.New(dir, hooks, func(err error) { warn(err) })
+	return webhooks, err
+}
+
+// Deliver webhooks in the background until ctx is done, for commands
+// running longer than an interval
+func runWebhooks(ctx context.Context) {
+	if webhooks != nil {
+		go webhooks.Run(ctx)
+	}
+}
+
+// Deliver the transitions made by the command before it exits
+func flushWebhooks(ctx context.Context) {
+	if webhooks == nil || webhooks.Enqueued() == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookFlushTimeout)
+	defer cancel()
+	webhooks.Flush(ctx)
+}
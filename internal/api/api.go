@@ -0,0 +1,287 @@
+// Package api serves the timer, history and reports over HTTP for
+// dashboards and editor plugins.
+//
+// Endpoints under /api/v1 answer with JSON, durations are nanoseconds
+// and states are numbered as in models:
+//
+//	GET  /api/v1/status                           current status
+//	POST /api/v1/start|resume|pause|stop|skip     control the timer, answers the status after it
+//	GET  /api/v1/intervals?from=&to=              intervals of the days, as export -f json
+//	GET  /api/v1/report?from=&to=&group=          totals of the days, as report
+//	GET  /api/v1/events                           server-sent events of the daemon protocol
+//	GET  /metrics                                 Prometheus metrics, when served
+//
+// Days are YYYY-MM-DD, by default the last 7 days up to today. Errors
+// are answered as {"error":"..."}.
+//
+// Requests must be addressed to a loopback Host, so pages of rebound
+// DNS names cannot read the API, and requests changing the timer are
+// refused from pages of other sites, told by Origin or Sec-Fetch-Site.
+//
+// With a token every request needs an "Authorization: Bearer <token>"
+// header or, for EventSource which cannot set headers, an access_token
+// query parameter.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/export"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Days of history and reports without from
+const defaultDays = 7
+
+var ErrInvalidParam = fmt.Errorf("Invalid parameter")
+
+type handler struct {
+	config *models.IntervalConfig
+	timer  daemon.Timer
+	token  string
+	mux    *http.ServeMux
+}
+
+// NewHandler serves the API and metrics at /metrics unless nil, requests
+// need token unless it is empty
+func NewHandler(config *models.IntervalConfig, timer daemon.Timer, metrics http.Handler, token string) http.Handler {
+	h := &handler{
+		config: config,
+		timer:  timer,
+		token:  token,
+		mux:    http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("/api/v1/status", allow(http.MethodGet, h.status))
+	for _, cmd := range []string{daemon.CmdStart, daemon.CmdResume,
+		daemon.CmdPause, daemon.CmdStop, daemon.CmdSkip} {
+		h.mux.HandleFunc("/api/v1/"+cmd, allow(http.MethodPost, h.command(cmd)))
+	}
+	h.mux.HandleFunc("/api/v1/intervals", allow(http.MethodGet, h.intervals))
+	h.mux.HandleFunc("/api/v1/report", allow(http.MethodGet, h.report))
+	h.mux.HandleFunc("/api/v1/events", allow(http.MethodGet, h.events))
+	if metrics != nil {
+		h.mux.Handle("/metrics", allow(http.MethodGet, metrics.ServeHTTP))
+	}
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !loopback(r.Host) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("Host %q not allowed", r.Host))
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && crossSite(r) {
+		writeError(w, http.StatusForbidden, errors.New("Cross-site request not allowed"))
+		return
+	}
+	if h.token != "" && !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="pomodoro-go"`)
+		writeError(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *handler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("access_token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+// Whether host, with or without port, names this machine
+func loopback(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Whether r comes from a page of another site. Pages on other ports of
+// localhost, like a dashboard in development, are allowed.
+func crossSite(r *http.Request) bool {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err != nil || !loopback(u.Host)
+}
+
+// Answer other methods with 405
+func allow(method string, fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed,
+				fmt.Errorf("Method %s not allowed", r.Method))
+			return
+		}
+		fn(w, r)
+	}
+}
+
+func (h *handler) status(w http.ResponseWriter, r *http.Request) {
+	s, err := h.timer.Do(r.Context(), daemon.CmdStatus)
+	if err != nil {
+		writeError(w, errorCode(err), err)
+		return
+	}
+	writeJSON(w, s)
+}
+
+func (h *handler) command(cmd string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := h.timer.Do(r.Context(), cmd)
+		if err != nil {
+			writeError(w, errorCode(err), err)
+			return
+		}
+		writeJSON(w, s)
+	}
+}
+
+func (h *handler) intervals(w http.ResponseWriter, r *http.Request) {
+	from, to, err := h.days(r)
+	if err != nil {
+		writeError(w, errorCode(err), err)
+		return
+	}
+	start, _ := h.config.DateBounds(from)
+	_, end := h.config.DateBounds(to)
+
+	// Errors once the array is streamed can only cut it short
+	w.Header().Set("Content-Type", "application/json")
+	ew, err := export.NewWriter(export.FormatJSON, w)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := h.config.Repo.Range(r.Context(), start, end, ew.Write); err != nil {
+		return
+	}
+	ew.Close()
+}
+
+func (h *handler) report(w http.ResponseWriter, r *http.Request) {
+	from, to, err := h.days(r)
+	if err != nil {
+		writeError(w, errorCode(err), err)
+		return
+	}
+	group := r.URL.Query().Get("group")
+	if group == "" {
+		group = models.GroupDay
+	}
+
+	report, err := models.NewReport(r.Context(), h.config, from, to, group)
+	if err != nil {
+		writeError(w, errorCode(err), err)
+		return
+	}
+	writeJSON(w, report)
+}
+
+// Stream events until the client goes away
+func (h *handler) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("Streaming not supported"))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	h.timer.Subscribe(ctx, func(ev daemon.Event) {
+		data, err := json.Marshal(ev.Status)
+		if err == nil {
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Name, data)
+		}
+		if err != nil {
+			cancel()
+			return
+		}
+		flusher.Flush()
+	})
+}
+
+// Return days of the from and to parameters
+func (h *handler) days(r *http.Request) (time.Time, time.Time, error) {
+	q := r.URL.Query()
+	to := h.config.Day(time.Now())
+	if s := q.Get("to"); s != "" {
+		t, err := time.ParseInLocation("2006-01-02", s, to.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: to: %q", ErrInvalidParam, s)
+		}
+		to = t
+	}
+
+	from := to.AddDate(0, 0, 1-defaultDays)
+	if s := q.Get("from"); s != "" {
+		t, err := time.ParseInLocation("2006-01-02", s, to.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: from: %q", ErrInvalidParam, s)
+		}
+		from = t
+	}
+	if from.After(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from %s is after to %s",
+			ErrInvalidParam, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	}
+	return from, to, nil
+}
+
+// Return HTTP status code of err
+func errorCode(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidParam), errors.Is(err, models.ErrInvalidGroup):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrNoIntervals):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrIntervalNotRunning),
+		errors.Is(err, models.ErrIntervalNotPaused),
+		errors.Is(err, models.ErrIntervalCompleted):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
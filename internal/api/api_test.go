@@ -0,0 +1,227 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/repository"
+)
+
+const token = "secret"
+
+func request(t *testing.T, method, url string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// Request url and decode the JSON answer into v
+func decode(t *testing.T, method, url string, code int, v any) {
+	t.Helper()
+	resp := request(t, method, url)
+	defer resp.Body.Close()
+	if resp.StatusCode != code {
+		t.Fatalf("%s %s: expected status %d, got %d", method, url, code, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+}
+
+func TestAPI(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config, err := models.NewConfig(repository.NewInMemoryRepo(), time.Second, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timer := daemon.NewServer(config)
+	defer timer.Close()
+	srv := httptest.NewServer(NewHandler(config, timer, nil, token))
+	defer srv.Close()
+	api := srv.URL + "/api/v1"
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		resp, err := http.Get(api + "/status")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+		}
+
+		resp, err = http.Get(api + "/status?access_token=" + token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status %d with query token, got %d", http.StatusOK, resp.StatusCode)
+		}
+	})
+
+	t.Run("Host", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, api+"/status", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		for host, code := range map[string]int{
+			"rebound.example.com:8425": http.StatusForbidden,
+			"192.168.1.2":              http.StatusForbidden,
+			"localhost:8425":           http.StatusOK,
+			"[::1]:8425":               http.StatusOK,
+		} {
+			req.Host = host
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != code {
+				t.Errorf("Host %s: expected status %d, got %d", host, code, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("CrossSite", func(t *testing.T) {
+		// No interval yet, allowed requests answer not found
+		for _, tc := range []struct {
+			header, value string
+			code          int
+		}{
+			{"Origin", "https://evil.example.com", http.StatusForbidden},
+			{"Origin", "null", http.StatusForbidden},
+			{"Sec-Fetch-Site", "cross-site", http.StatusForbidden},
+			{"Origin", "http://localhost:3000", http.StatusNotFound},
+			{"Sec-Fetch-Site", "same-site", http.StatusNotFound},
+		} {
+			req, err := http.NewRequest(http.MethodPost, api+"/pause", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set(tc.header, tc.value)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.code {
+				t.Errorf("%s %s: expected status %d, got %d", tc.header, tc.value, tc.code, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		resp := request(t, http.MethodGet, api+"/start")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, resp.StatusCode)
+		}
+	})
+
+	// Subscribe before starting so the events are not missed
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, api+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	stream, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	if ct := stream.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected event stream, got %q", ct)
+	}
+	events := make(chan string, 64)
+	go func() {
+		sc := bufio.NewScanner(stream.Body)
+		for sc.Scan() {
+			if name, ok := strings.CutPrefix(sc.Text(), "event: "); ok {
+				events <- name
+			}
+		}
+	}()
+	waitEvent := func(name string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case ev := <-events:
+				if ev == name {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("Timeout waiting for %s event", name)
+			}
+		}
+	}
+	waitEvent(daemon.EventStatus)
+
+	s := models.Status{}
+	decode(t, http.MethodPost, api+"/start", http.StatusOK, &s)
+	if s.Category != models.PomodoCategory || s.State != models.StateRunning {
+		t.Errorf("Expected running pomodoro, got %+v", s)
+	}
+	waitEvent(daemon.EventStart)
+
+	decode(t, http.MethodPost, api+"/pause", http.StatusOK, &s)
+	if s.State != models.StatePaused {
+		t.Errorf("Expected paused pomodoro, got %+v", s)
+	}
+	waitEvent(daemon.EventPause)
+
+	apiErr := struct{ Error string }{}
+	decode(t, http.MethodPost, api+"/pause", http.StatusConflict, &apiErr)
+	if !strings.HasPrefix(apiErr.Error, models.ErrIntervalNotRunning.Error()) {
+		t.Errorf("Expected error %q, got %q", models.ErrIntervalNotRunning, apiErr.Error)
+	}
+
+	decode(t, http.MethodPost, api+"/resume", http.StatusOK, &s)
+	waitEvent(daemon.EventDone)
+
+	decode(t, http.MethodGet, api+"/status", http.StatusOK, &s)
+	if s.State != models.StateDone || s.Today != 1 {
+		t.Errorf("Expected finished pomodoro, got %+v", s)
+	}
+
+	intervals := []map[string]any{}
+	decode(t, http.MethodGet, api+"/intervals", http.StatusOK, &intervals)
+	if len(intervals) != 1 {
+		t.Errorf("Expected 1 interval, got %d", len(intervals))
+	}
+
+	report := models.Report{}
+	decode(t, http.MethodGet, api+"/report?group=category", http.StatusOK, &report)
+	if report.Days != defaultDays || report.Total.Pomodoros != 1 {
+		t.Errorf("Expected 1 pomodoro in %d days, got %+v", defaultDays, report)
+	}
+
+	for _, url := range []string{
+		api + "/report?group=year",
+		api + "/report?from=yesterday",
+		api + "/intervals?from=2023-09-02&to=2023-09-01",
+	} {
+		decode(t, http.MethodGet, url, http.StatusBadRequest, &apiErr)
+	}
+}
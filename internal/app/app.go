@@ -2,25 +2,39 @@ package app
 
 import (
 	"context"
+	"errors"
 	"image"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mum4k/termdash"
 	"github.com/mum4k/termdash/terminal/tcell"
 	"github.com/mum4k/termdash/terminal/terminalapi"
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
 	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/osc"
 )
 
+// Time to wait on quit for running intervals to record their state
+const shutdownGrace = 3 * time.Second
+
 type App struct {
 	ctx        context.Context
 	controller *termdash.Controller
 	redrawCh   chan bool
 	errorCh    chan error
+	w          *widgets
+	b          *buttons
 	term       *tcell.Terminal
+	seq        *osc.Writer
 	size       image.Point
+	wg         *sync.WaitGroup
 }
 
-func New(config *models.IntervalConfig) (*App, error) {
+// New dashboard, with a client the intervals run in the daemon. seq
+// reports the timer to the terminal, e.g. in the window title.
+func New(config *models.IntervalConfig, client *daemon.Client, seq *osc.Writer) (*App, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	quitter := func(k *terminalapi.Keyboard) {
@@ -30,6 +44,7 @@ func New(config *models.IntervalConfig) (*App, error) {
 	}
 	redrawCh := make(chan bool)
 	errorCh := make(chan error)
+	wg := &sync.WaitGroup{}
 
 	audioCtx := InitSound()
 
@@ -42,10 +57,19 @@ func New(config *models.IntervalConfig) (*App, error) {
 		return nil, err
 	}
 
-	b, err := newButtons(ctx, config, w, s, audioCtx, redrawCh, errorCh)
+	// Number of start presses whose interval may still tick in this
+	// process
+	local := &atomic.Int32{}
+
+	b, err := newButtons(ctx, config, client, w, s, seq, audioCtx, wg, local, redrawCh, errorCh)
 	if err != nil {
 		return nil, err
 	}
+	if client != nil {
+		go watchDaemon(ctx, config, client, w, s, seq, audioCtx, redrawCh, errorCh)
+	} else {
+		go watchRepo(ctx, config, w, s, seq, local, redrawCh, errorCh)
+	}
 	term, err := tcell.New()
 	if err != nil {
 		return nil, err
@@ -65,10 +89,29 @@ func New(config *models.IntervalConfig) (*App, error) {
 		controller: controller,
 		redrawCh:   redrawCh,
 		errorCh:    errorCh,
+		w:          w,
+		b:          b,
 		term:       term,
+		seq:        seq,
+		wg:         wg,
 	}, nil
 }
 
+// Start the next interval as the start button does
+func (a *App) Start() {
+	a.b.start()
+}
+
+// Warn shows err in the info text without stopping the dashboard, or
+// drops it once the dashboard is gone. Meetings a pomodoro is planned
+// around are told by the start message.
+func (a *App) Warn(err error) {
+	if errors.Is(err, models.ErrEventOverlap) {
+		return
+	}
+	go a.w.update([]int{}, err.Error(), "", "", a.redrawCh)
+}
+
 func (a *App) resize() error {
 	if a.size.Eq(a.term.Size()) {
 		return nil
@@ -82,6 +125,7 @@ func (a *App) resize() error {
 }
 
 func (a *App) Run() error {
+	defer a.seq.Close()
 	defer a.term.Close()
 	defer a.controller.Close()
 
@@ -99,6 +143,7 @@ func (a *App) Run() error {
 				return err
 			}
 		case <-a.ctx.Done():
+			a.shutdown()
 			return nil
 		case <-ticker.C:
 			if err := a.resize(); err != nil {
@@ -107,3 +152,26 @@ func (a *App) Run() error {
 		}
 	}
 }
+
+// Wait for running intervals to record their cancellation.
+// Repository calls are already canceled with the context, so this
+// is bounded by shutdownGrace.
+func (a *App) shutdown() {
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+
+	timeout := time.After(shutdownGrace)
+	for {
+		select {
+		case <-done:
+			return
+		case <-timeout:
+			return
+		case <-a.redrawCh:
+		case <-a.errorCh:
+		}
+	}
+}
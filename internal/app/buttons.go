@@ -2,36 +2,46 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 
 	"github.com/ebitengine/oto/v3"
 	"github.com/mum4k/termdash/cell"
 	"github.com/mum4k/termdash/widgets/button"
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
 	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/osc"
 )
 
 type buttons struct {
 	btStart *button.Button
 	btPause *button.Button
+	// Pick the task of the next pomodoro and mark it done, nil without
+	// tasks or when the task source can not complete them
+	btTask *button.Button
+	btDone *button.Button
+	// Same as pressing start
+	start func()
 }
 
-func newButtons(ctx context.Context, config *models.IntervalConfig, w *widgets, s *summary, audioCtx *oto.Context, redrawCh chan<- bool, errorCh chan<- error) (*buttons, error) {
+func newButtons(ctx context.Context, config *models.IntervalConfig, client *daemon.Client, w *widgets, s *summary, seq *osc.Writer, audioCtx *oto.Context, wg *sync.WaitGroup, local *atomic.Int32, redrawCh chan<- bool, errorCh chan<- error) (*buttons, error) {
 	startInterval := func() {
-		i, err := models.GetInterval(config)
+		i, err := models.GetInterval(ctx, config)
 		errorCh <- err
 		start := func(i models.Interval) {
-			message := "Take a brake"
-			if i.Category == models.PomodoCategory {
-				message = "Focus on your task"
-			}
-			w.update([]int{}, message, "", i.Category, redrawCh)
+			w.update([]int{}, startMessage(config, i.Category, i.TimePlanning, i.TimePlanning-i.TimeActual), "", i.Category, redrawCh)
+			seq.Running(i.Category, i.TimePlanning-i.TimeActual)
 		}
-		end := func(models.Interval) {
+		end := func(i models.Interval) {
 			w.update([]int{}, "", "Nothing running", "", redrawCh)
 			s.update(redrawCh)
+			seq.Done(i.Category)
 			go SoundPlay(audioCtx)
 		}
 		periodic := func(i models.Interval) {
+			seq.Running(i.Category, i.TimePlanning-i.TimeActual)
 			w.update(
 				[]int{int(i.TimeActual), int(i.TimePlanning)},
 				"",
@@ -43,12 +53,12 @@ func newButtons(ctx context.Context, config *models.IntervalConfig, w *widgets,
 		errorCh <- i.Start(ctx, config, start, periodic, end)
 	}
 	pauseInterval := func() {
-		i, err := models.GetInterval(config)
+		i, err := models.GetInterval(ctx, config)
 		if err != nil {
 			errorCh <- err
 		}
 
-		if err := i.Pause(config); err != nil {
+		if err := i.Pause(ctx, config); err != nil {
 			if err == models.ErrIntervalNotRunning {
 				return
 			}
@@ -56,10 +66,36 @@ func newButtons(ctx context.Context, config *models.IntervalConfig, w *widgets,
 			return
 		}
 		w.update([]int{}, "Paused, press start to continue...", "", "", redrawCh)
+		seq.Paused(i.Category, i.TimePlanning-i.TimeActual)
+	}
+
+	// The daemon runs the interval, its events update the widgets
+	if client != nil {
+		startInterval = func() {
+			if _, err := client.Do(ctx, daemon.CmdStart); err != nil && ctx.Err() == nil {
+				errorCh <- err
+			}
+		}
+		pauseInterval = func() {
+			_, err := client.Do(ctx, daemon.CmdPause)
+			if err != nil && !errors.Is(err, models.ErrIntervalNotRunning) && ctx.Err() == nil {
+				errorCh <- err
+			}
+		}
+	}
+
+	start := func() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			local.Add(1)
+			defer local.Add(-1)
+			startInterval()
+		}()
 	}
 
 	btStart, err := button.New("(s)tart", func() error {
-		go startInterval()
+		start()
 		return nil
 	},
 		button.GlobalKey('s'),
@@ -82,6 +118,45 @@ func newButtons(ctx context.Context, config *models.IntervalConfig, w *widgets,
 		return nil, err
 	}
 
-	return &buttons{btStart: btStart, btPause: btPause}, nil
+	b := &buttons{btStart: btStart, btPause: btPause, start: start}
+
+	// The daemon records its own task on pomodoros
+	if config.Tasks == nil || client != nil {
+		return b, nil
+	}
+	picker := newTaskPicker(config)
+	// Failures of the task source are shown, the timer goes on
+	show := func(action func() (string, error)) func() error {
+		return func() error {
+			go func() {
+				message, err := action()
+				if err != nil {
+					message = err.Error()
+				}
+				w.update([]int{}, message, "", "", redrawCh)
+			}()
+			return nil
+		}
+	}
 
+	b.btTask, err = button.New("(t)ask", show(picker.next),
+		button.GlobalKey('t'),
+		button.WidthFor("(p)ause"),
+		button.Height(2),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := config.Tasks.(models.TaskCompleter); !ok {
+		return b, nil
+	}
+	b.btDone, err = button.New("(x) done", show(picker.complete),
+		button.GlobalKey('x'),
+		button.WidthFor("(x) done"),
+		button.Height(2),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
 }
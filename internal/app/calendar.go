@@ -0,0 +1,39 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Info text of a started interval planned for planned with remaining
+// left, with the next meeting and how a pomodoro runs into it. Planning
+// around meetings is told here rather than warned about, which would
+// race the start message.
+func startMessage(config *models.IntervalConfig, category string, planned, remaining time.Duration) string {
+	message := "Take a brake"
+	if category == models.PomodoCategory {
+		message = "Focus on your task"
+	}
+
+	now := time.Now()
+	e, ok, err := models.NextEvent(config, now)
+	if err != nil || !ok {
+		return message
+	}
+	loc := config.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	next := fmt.Sprintf("%s at %s", e.Summary, e.Start.In(loc).Format("15:04"))
+
+	switch {
+	case category != models.PomodoCategory:
+	case e.Start.Before(now.Add(remaining)):
+		return fmt.Sprintf("%s\nOverlaps: %s", message, next)
+	case config.ShortenForEvents && planned < config.PomoDuration:
+		return fmt.Sprintf("%s\nShortened to %s before: %s", message, planned, next)
+	}
+	return fmt.Sprintf("%s\nNext: %s", message, next)
+}
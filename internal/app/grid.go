@@ -6,6 +6,7 @@ import (
 	"github.com/mum4k/termdash/container/grid"
 	"github.com/mum4k/termdash/linestyle"
 	"github.com/mum4k/termdash/terminal/terminalapi"
+	"github.com/mum4k/termdash/widgets/button"
 )
 
 func newGrid(b *buttons, w *widgets, s *summary,
@@ -46,17 +47,22 @@ func newGrid(b *buttons, w *widgets, s *summary,
 		),
 	)
 
-	// Add second row
-	builder.Add(
-		grid.RowHeightPerc(10,
-			grid.ColWidthPerc(50,
-				grid.Widget(b.btStart),
-			),
-			grid.ColWidthPerc(50,
-				grid.Widget(b.btPause),
-			),
-		),
-	)
+	// Add second row, the task buttons share it when there are tasks
+	bts := []*button.Button{b.btStart, b.btPause}
+	for _, bt := range []*button.Button{b.btTask, b.btDone} {
+		if bt != nil {
+			bts = append(bts, bt)
+		}
+	}
+	cols := []grid.Element{}
+	for n, bt := range bts {
+		width := 100 / len(bts)
+		if n == len(bts)-1 {
+			width = 100 - width*(len(bts)-1)
+		}
+		cols = append(cols, grid.ColWidthPerc(width, grid.Widget(bt)))
+	}
+	builder.Add(grid.RowHeightPerc(10, cols...))
 
 	// Add third row
 	builder.Add(
@@ -46,20 +46,23 @@ func newSummary(ctx context.Context, config *models.IntervalConfig,
 
 func newBarChart(ctx context.Context, config *models.IntervalConfig,
 	update <-chan bool, errorCh chan<- error) (*barchart.BarChart, error) {
+	colors := []cell.Color{cell.ColorBlue, cell.ColorYellow}
+	labels := []string{"Pomodoro", "Break"}
+	// Working hours left after meetings
+	capacity := config.WorkdayEnd > config.WorkdayStart
+	if capacity {
+		colors = append(colors, cell.ColorGreen)
+		labels = append(labels, "Capacity")
+	}
 	bc, err := barchart.New(
 		barchart.ShowValues(),
-		barchart.BarColors([]cell.Color{
-			cell.ColorBlue,
-			cell.ColorYellow,
-		}),
+		barchart.BarColors(colors),
 		barchart.ValueColors([]cell.Color{
 			cell.ColorBlack,
 			cell.ColorBlack,
+			cell.ColorBlack,
 		}),
-		barchart.Labels([]string{
-			"Pomodoro",
-			"Break",
-		}),
+		barchart.Labels(labels),
 	)
 	if err != nil {
 		return nil, err
@@ -67,17 +70,24 @@ func newBarChart(ctx context.Context, config *models.IntervalConfig,
 
 	// Update function for BarChart
 	updateWidget := func() error {
-		ds, err := models.DailySummary(time.Now(), config)
+		ds, err := models.DailySummary(ctx, time.Now(), config)
 		if err != nil {
 			return err
 		}
 
-		return bc.Values(
-			[]int{int(ds[0].Minutes()),
-				int(ds[1].Minutes())},
-			int(math.Max(ds[0].Minutes(),
-				ds[1].Minutes())*1.1)+1,
-		)
+		values := []int{int(ds[0].Minutes()),
+			int(ds[1].Minutes())}
+		max := math.Max(ds[0].Minutes(), ds[1].Minutes())
+		if capacity {
+			c, err := models.DailyCapacity(time.Now(), config)
+			if err != nil {
+				return err
+			}
+			values = append(values, int(c.Minutes()))
+			max = math.Max(max, c.Minutes())
+		}
+
+		return bc.Values(values, int(max*1.1)+1)
 	}
 
 	go func() {
@@ -116,7 +126,7 @@ func newLineChart(ctx context.Context, config *models.IntervalConfig,
 
 	// Update function for linechart
 	updateWidget := func() error {
-		ws, err := models.RangeSummary(time.Now(), 7, config)
+		ws, err := models.RangeSummary(ctx, time.Now(), 7, config)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Cycles the task of the next pomodoros through the tasks of the
+// configured source, and back to the configured task after the last
+type taskPicker struct {
+	config *models.IntervalConfig
+
+	mu      sync.Mutex
+	initial string
+	tasks   []models.Task
+	// Index of the picked task, -1 for the configured one
+	pos int
+}
+
+func newTaskPicker(config *models.IntervalConfig) *taskPicker {
+	return &taskPicker{config: config, initial: config.Task, pos: -1}
+}
+
+// Task of the next pomodoros
+func (p *taskPicker) current() models.Task {
+	if t, ok := p.config.Picked.Get(); ok {
+		return t
+	}
+	return models.Task{Description: p.initial}
+}
+
+// Pick the next task and return the info text telling about it. Tasks
+// are listed again after going through all of them.
+func (p *taskPicker) next() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pos < 0 {
+		tasks, err := p.config.Tasks.Tasks()
+		if err != nil {
+			return "", err
+		}
+		p.tasks = tasks
+	}
+
+	p.pos++
+	task := models.Task{Description: p.initial}
+	if p.pos < len(p.tasks) {
+		task = p.tasks[p.pos]
+	} else {
+		p.pos = -1
+	}
+	p.config.Picked.Pick(task)
+
+	if task.Description == "" {
+		return "No task for the next pomodoro", nil
+	}
+	if p.pos < 0 {
+		return fmt.Sprintf("Next pomodoro: %s", task.Description), nil
+	}
+	return fmt.Sprintf("Next pomodoro: %s (%d/%d)", task.Description, p.pos+1, len(p.tasks)), nil
+}
+
+// Mark the task of the next pomodoros done and return the info text
+// telling about it. The configured task is picked again.
+func (p *taskPicker) complete() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	task := p.current()
+	if task.Description == "" {
+		return "No task to mark done, press t to pick one", nil
+	}
+	if err := p.config.Tasks.(models.TaskCompleter).Complete(task); err != nil {
+		return "", err
+	}
+
+	if p.initial == task.Description {
+		p.initial = ""
+	}
+	p.config.Picked.Pick(models.Task{Description: p.initial})
+	p.pos = -1
+	return fmt.Sprintf("Done: %s", task.Description), nil
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ebitengine/oto/v3"
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/osc"
+)
+
+// Follow intervals driven by other processes, like the headless
+// commands, while no interval is ticking in this one
+func watchRepo(ctx context.Context, config *models.IntervalConfig, w *widgets, s *summary,
+	seq *osc.Writer, local *atomic.Int32, redrawCh chan<- bool, errorCh chan<- error) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	prev := models.Interval{}
+	// Last interval ticked by this process, which showed its end itself
+	var localID int64
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		i, err := config.Repo.Last(ctx)
+		if errors.Is(err, models.ErrNoIntervals) || ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			errorCh <- err
+			continue
+		}
+
+		changed := i.ID != prev.ID || i.State != prev.State ||
+			i.TimeActual != prev.TimeActual || !i.TimeStart.Equal(prev.TimeStart)
+		finished := i.ID == prev.ID && i.State != prev.State &&
+			models.Ended(i.State)
+		prev = i
+		ticking := local.Load() > 0
+		if ticking {
+			localID = i.ID
+		}
+		if !changed || ticking {
+			continue
+		}
+
+		switch i.State {
+		case models.StateRunning:
+			w.update(
+				[]int{int(i.TimeActual), int(i.TimePlanning)},
+				startMessage(config, i.Category, i.TimePlanning, i.TimePlanning-i.TimeActual),
+				fmt.Sprint(i.TimePlanning-i.TimeActual),
+				i.Category,
+				redrawCh,
+			)
+			seq.Running(i.Category, i.TimePlanning-i.TimeActual)
+		case models.StatePaused:
+			w.update([]int{int(i.TimeActual), int(i.TimePlanning)},
+				"Paused, press start to continue...",
+				fmt.Sprint(i.TimePlanning-i.TimeActual), i.Category, redrawCh)
+			seq.Paused(i.Category, i.TimePlanning-i.TimeActual)
+		}
+
+		if finished && i.ID != localID {
+			w.update([]int{}, "", "Nothing running", "", redrawCh)
+			s.update(redrawCh)
+			if i.State == models.StateDone {
+				seq.Done(i.Category)
+			} else {
+				seq.Idle()
+			}
+		}
+	}
+}
+
+// Follow the intervals run by the daemon
+func watchDaemon(ctx context.Context, config *models.IntervalConfig, client *daemon.Client, w *widgets, s *summary,
+	seq *osc.Writer, audioCtx *oto.Context, redrawCh chan<- bool, errorCh chan<- error) {
+	err := client.Subscribe(ctx, func(ev daemon.Event) {
+		st := ev.Status
+		timer := []int{int(st.Planned - st.Remaining), int(st.Planned)}
+
+		switch ev.Name {
+		case daemon.EventStatus, daemon.EventStart:
+			switch st.State {
+			case models.StateRunning:
+				w.update(timer, startMessage(config, st.Category, st.Planned, st.Remaining), fmt.Sprint(st.Remaining), st.Category, redrawCh)
+				seq.Running(st.Category, st.Remaining)
+			case models.StatePaused:
+				w.update(timer, "Paused, press start to continue...",
+					fmt.Sprint(st.Remaining), st.Category, redrawCh)
+				seq.Paused(st.Category, st.Remaining)
+			}
+		case daemon.EventTick:
+			w.update(timer, "", fmt.Sprint(st.Remaining), "", redrawCh)
+			seq.Running(st.Category, st.Remaining)
+		case daemon.EventPause:
+			w.update(timer, "Paused, press start to continue...", "", "", redrawCh)
+			seq.Paused(st.Category, st.Remaining)
+		case daemon.EventDone:
+			w.update([]int{}, "", "Nothing running", "", redrawCh)
+			s.update(redrawCh)
+			seq.Done(st.Category)
+			go SoundPlay(audioCtx)
+		case daemon.EventStop, daemon.EventSkip:
+			w.update([]int{}, "", "Nothing running", "", redrawCh)
+			s.update(redrawCh)
+			seq.Idle()
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		errorCh <- fmt.Errorf("daemon: %w", err)
+	}
+}
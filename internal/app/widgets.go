@@ -10,6 +10,8 @@ import (
 )
 
 type widgets struct {
+	// Done when the dashboard is gone, nobody reads the updates then
+	ctx            context.Context
 	donTimer       *donut.Donut
 	disType        *segmentdisplay.SegmentDisplay
 	txtInfo        *text.Text
@@ -20,24 +22,35 @@ type widgets struct {
 	updateTxtType  chan string
 }
 
+// Update the widgets, dropped once the dashboard is gone
 func (w *widgets) update(timer []int, txtInfo, txtTimer, txtType string, redrawCh chan<- bool) {
-	if len(timer) > 0 {
-		w.updateDonTimer <- timer
+	if len(timer) > 0 && !send(w.ctx, w.updateDonTimer, timer) {
+		return
 	}
-	if txtInfo != "" {
-		w.updateTxtInfo <- txtInfo
+	if txtInfo != "" && !send(w.ctx, w.updateTxtInfo, txtInfo) {
+		return
 	}
-	if txtTimer != "" {
-		w.updateTxtTimer <- txtTimer
+	if txtTimer != "" && !send(w.ctx, w.updateTxtTimer, txtTimer) {
+		return
 	}
-	if txtType != "" {
-		w.updateTxtType <- txtType
+	if txtType != "" && !send(w.ctx, w.updateTxtType, txtType) {
+		return
+	}
+	send(w.ctx, redrawCh, true)
+}
+
+// Send v on ch, false if ctx is done first
+func send[T any](ctx context.Context, ch chan<- T, v T) bool {
+	select {
+	case ch <- v:
+		return true
+	case <-ctx.Done():
+		return false
 	}
-	redrawCh <- true
 }
 
 func newWidgets(ctx context.Context, errorCh chan<- error) (*widgets, error) {
-	w := &widgets{}
+	w := &widgets{ctx: ctx}
 	var err error
 
 	w.updateDonTimer = make(chan []int)
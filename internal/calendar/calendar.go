@@ -0,0 +1,163 @@
+// Package calendar reads meetings from iCalendar (.ics) files, like the
+// exports and local caches of calendar clients.
+//
+// Recurring events are expanded, with their exceptions and modified
+// occurrences. All-day, free (TRANSP:TRANSPARENT) and cancelled events
+// are not meetings and are left out. Files are read again when they
+// change.
+package calendar
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// ICS is a models.Calendar of the events in a set of files
+type ICS struct {
+	paths []string
+	loc   *time.Location
+
+	mu    sync.Mutex
+	files map[string]*file
+}
+
+type file struct {
+	mod    time.Time
+	size   int64
+	events []ical.Event
+	// Occurrences replaced by modified ones, by UID and start
+	overridden map[occurrence]bool
+}
+
+type occurrence struct {
+	uid   string
+	start int64
+}
+
+// New calendar of the files at paths, floating times are taken in loc
+func New(loc *time.Location, paths ...string) *ICS {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &ICS{paths: paths, loc: loc, files: map[string]*file{}}
+}
+
+// Events overlapping [start, end) in order of start time
+func (c *ICS) Events(start, end time.Time) ([]models.Event, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	events := []models.Event{}
+	for _, path := range c.paths {
+		f, err := c.load(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range f.events {
+			events = append(events, c.expand(f, e, start, end)...)
+		}
+	}
+	sort.SliceStable(events, func(a, b int) bool { return events[a].Start.Before(events[b].Start) })
+	return events, nil
+}
+
+// Return the parsed file at path, read again after changes
+func (c *ICS) load(path string) (*file, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := c.files[path]; ok && f.mod.Equal(fi.ModTime()) && f.size == fi.Size() {
+		return f, nil
+	}
+
+	r, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	cal, err := ical.NewDecoder(r).Decode()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	f := &file{mod: fi.ModTime(), size: fi.Size(), overridden: map[occurrence]bool{}}
+	for _, e := range cal.Events() {
+		if !meeting(e) {
+			continue
+		}
+		f.events = append(f.events, e)
+		if id := e.Props.Get(ical.PropRecurrenceID); id != nil {
+			if t, err := id.DateTime(c.loc); err == nil {
+				f.overridden[occurrence{uid(e), t.Unix()}] = true
+			}
+		}
+	}
+	c.files[path] = f
+	return f, nil
+}
+
+// Is e a timed event keeping its attendees busy
+func meeting(e ical.Event) bool {
+	start := e.Props.Get(ical.PropDateTimeStart)
+	if start == nil || start.ValueType() == ical.ValueDate || len(start.Value) == len("20060102") {
+		return false
+	}
+	if status, err := e.Status(); err != nil || status == ical.EventCancelled {
+		return false
+	}
+	transp, _ := e.Props.Text(ical.PropTransparency)
+	return transp != "TRANSPARENT"
+}
+
+func uid(e ical.Event) string {
+	s, _ := e.Props.Text(ical.PropUID)
+	return s
+}
+
+// Return occurrences of e overlapping [start, end), events which can
+// not be read are skipped
+func (c *ICS) expand(f *file, e ical.Event, start, end time.Time) []models.Event {
+	first, err := e.DateTimeStart(c.loc)
+	if err != nil {
+		return nil
+	}
+	last, err := e.DateTimeEnd(c.loc)
+	if err != nil || last.Before(first) {
+		return nil
+	}
+	length := last.Sub(first)
+	summary, _ := e.Props.Text(ical.PropSummary)
+
+	// Modified occurrences are events of their own
+	master := e.Props.Get(ical.PropRecurrenceID) == nil
+	starts := []time.Time{first}
+	if master {
+		set, err := e.RecurrenceSet(c.loc)
+		if err != nil {
+			return nil
+		}
+		if set != nil {
+			starts = set.Between(start.Add(-length), end, true)
+		}
+	}
+
+	events := []models.Event{}
+	for _, s := range starts {
+		t := s.Add(length)
+		if !s.Before(end) || !t.After(start) {
+			continue
+		}
+		if master && f.overridden[occurrence{uid(e), s.Unix()}] {
+			continue
+		}
+		events = append(events, models.Event{Summary: summary, Start: s, End: t})
+	}
+	return events
+}
@@ -0,0 +1,145 @@
+package calendar
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+const ics = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//EN
+BEGIN:VEVENT
+UID:standup
+DTSTAMP:20230901T000000Z
+SUMMARY:Standup
+DTSTART;TZID=Europe/Berlin:20230904T100000
+DTEND;TZID=Europe/Berlin:20230904T101500
+RRULE:FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR
+EXDATE;TZID=Europe/Berlin:20230905T100000
+END:VEVENT
+BEGIN:VEVENT
+UID:standup
+DTSTAMP:20230901T000000Z
+RECURRENCE-ID;TZID=Europe/Berlin:20230906T100000
+SUMMARY:Standup moved
+DTSTART;TZID=Europe/Berlin:20230906T113000
+DTEND;TZID=Europe/Berlin:20230906T114500
+END:VEVENT
+BEGIN:VEVENT
+UID:review
+DTSTAMP:20230901T000000Z
+SUMMARY:Review
+DTSTART:20230906T130000Z
+DURATION:PT1H
+END:VEVENT
+BEGIN:VEVENT
+UID:holiday
+DTSTAMP:20230901T000000Z
+SUMMARY:Holiday
+DTSTART;VALUE=DATE:20230906
+DTEND;VALUE=DATE:20230907
+END:VEVENT
+BEGIN:VEVENT
+UID:focus
+DTSTAMP:20230901T000000Z
+SUMMARY:Focus block
+TRANSP:TRANSPARENT
+DTSTART:20230906T140000Z
+DTEND:20230906T150000Z
+END:VEVENT
+BEGIN:VEVENT
+UID:cancelled
+DTSTAMP:20230901T000000Z
+SUMMARY:Cancelled
+STATUS:CANCELLED
+DTSTART:20230906T150000Z
+DTEND:20230906T160000Z
+END:VEVENT
+END:VCALENDAR
+`
+
+func format(events []models.Event) string {
+	lines := []string{}
+	for _, e := range events {
+		lines = append(lines, e.Start.UTC().Format("01-02 15:04")+"-"+
+			e.End.UTC().Format("15:04")+" "+e.Summary)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "work.ics")
+	if err := os.WriteFile(path, []byte(strings.ReplaceAll(ics, "\n", "\r\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c := New(time.UTC, path)
+
+	day := func(d int) (time.Time, time.Time) {
+		start := time.Date(2023, 9, d, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1)
+	}
+	testCases := []struct {
+		name string
+		day  int
+		exp  string
+	}{
+		{"Recurring", 4, "09-04 08:00-08:15 Standup"},
+		{"Exception", 5, ""},
+		{"Modified", 6, "09-06 09:30-09:45 Standup moved\n09-06 13:00-14:00 Review"},
+		{"Weekend", 9, ""},
+		{"Recurring later", 11, "09-11 08:00-08:15 Standup"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			events, err := c.Events(day(tc.day))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := format(events); got != tc.exp {
+				t.Errorf("Expected\n%s\ngot\n%s", tc.exp, got)
+			}
+		})
+	}
+
+	t.Run("Overlap", func(t *testing.T) {
+		// Ongoing events are included
+		start := time.Date(2023, 9, 6, 13, 30, 0, 0, time.UTC)
+		events, err := c.Events(start, start.Add(time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := format(events); got != "09-06 13:00-14:00 Review" {
+			t.Errorf("Expected ongoing review, got %q", got)
+		}
+	})
+
+	t.Run("Reload", func(t *testing.T) {
+		changed := strings.Replace(ics, "SUMMARY:Review", "SUMMARY:Design review", 1)
+		if err := os.WriteFile(path, []byte(changed), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		later := time.Now().Add(time.Second)
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatal(err)
+		}
+		start := time.Date(2023, 9, 6, 13, 0, 0, 0, time.UTC)
+		events, err := c.Events(start, start.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || events[0].Summary != "Design review" {
+			t.Errorf("Expected changed file read again, got %v", events)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		if _, err := New(time.UTC, path+".missing").Events(day(4)); err == nil {
+			t.Error("Expected error for missing file")
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package internal_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Calendar of fixed events
+type events []models.Event
+
+func (es events) Events(start, end time.Time) ([]models.Event, error) {
+	res := []models.Event{}
+	for _, e := range es {
+		if e.Start.Before(end) && e.End.After(start) {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+func TestNewIntervalCalendar(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	meeting := models.Event{
+		Summary: "Standup",
+		Start:   now.Add(10*time.Minute + 30*time.Second),
+		End:     now.Add(25 * time.Minute),
+	}
+
+	testCases := []struct {
+		name    string
+		events  events
+		shorten bool
+		exp     time.Duration
+		warning string
+	}{
+		{"Free", events{{Summary: "Later", Start: now.Add(time.Hour), End: now.Add(2 * time.Hour)}}, true, 25 * time.Minute, ""},
+		{"Warn", events{meeting}, false, 25 * time.Minute, `"Standup" at`},
+		{"Shorten", events{meeting}, true, 10 * time.Minute, "shortened to 10m0s"},
+		{"Too close", events{{Summary: "Now", Start: now.Add(30 * time.Second), End: now.Add(time.Hour)}}, true, 25 * time.Minute, `"Now" at`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, cleanup := getRepo(t)
+			defer cleanup()
+			config, err := models.NewConfig(repo, 25*time.Minute, 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config.Calendar = tc.events
+			config.ShortenForEvents = tc.shorten
+			var warnings []error
+			config.Warn = func(err error) { warnings = append(warnings, err) }
+
+			i, err := models.NewInterval(ctx, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i.TimePlanning != tc.exp {
+				t.Errorf("Expected planned %s, got %s", tc.exp, i.TimePlanning)
+			}
+			stored, err := repo.ByID(ctx, i.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.TimePlanning != tc.exp {
+				t.Errorf("Expected stored planned %s, got %s", tc.exp, stored.TimePlanning)
+			}
+
+			if tc.warning == "" {
+				if len(warnings) != 0 {
+					t.Errorf("Expected no warnings, got %v", warnings)
+				}
+				return
+			}
+			if len(warnings) != 1 || !errors.Is(warnings[0], models.ErrEventOverlap) ||
+				!strings.Contains(warnings[0].Error(), tc.warning) {
+				t.Errorf("Expected overlap warning with %q, got %v", tc.warning, warnings)
+			}
+		})
+	}
+}
+
+func TestDailyCapacity(t *testing.T) {
+	day := time.Date(2023, 9, 6, 12, 0, 0, 0, time.UTC)
+	at := func(h, m int) time.Time { return time.Date(2023, 9, 6, h, m, 0, 0, time.UTC) }
+	config := &models.IntervalConfig{
+		Location: time.UTC,
+		Calendar: events{
+			{Summary: "Breakfast", Start: at(8, 0), End: at(9, 30)},
+			{Summary: "Planning", Start: at(11, 0), End: at(12, 0)},
+			{Summary: "Overlapping", Start: at(11, 30), End: at(12, 30)},
+			{Summary: "Evening", Start: at(18, 0), End: at(19, 0)},
+		},
+	}
+
+	capacity, err := models.DailyCapacity(day, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capacity != 0 {
+		t.Errorf("Expected no capacity without workday, got %s", capacity)
+	}
+
+	config.WorkdayStart, config.WorkdayEnd, err = models.ParseWorkday("09:00-17:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	capacity, err = models.DailyCapacity(day, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 8h less 30m of breakfast and 1h30m of meetings
+	if exp := 6 * time.Hour; capacity != exp {
+		t.Errorf("Expected capacity %s, got %s", exp, capacity)
+	}
+
+	for _, s := range []string{"17:00-09:00", "09:00", "9-17"} {
+		if _, _, err := models.ParseWorkday(s); !errors.Is(err, models.ErrInvalidWorkday) {
+			t.Errorf("Expected invalid workday %q, got %v", s, err)
+		}
+	}
+}
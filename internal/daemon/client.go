@@ -0,0 +1,144 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Client sends commands to the daemon over one connection
+type Client struct {
+	// Serializes requests on the connection
+	mu   sync.Mutex
+	path string
+	// Nil after a failed request, dialed again by the next one
+	conn net.Conn
+	dec  *json.Decoder
+	enc  *json.Encoder
+
+	// Sent with start when set, the daemon records it on new
+	// pomodoros. Set before the first request.
+	Origin func() Origin
+}
+
+// Connect to the daemon listening at path
+func Dial(path string) (*Client, error) {
+	c := &Client{path: path}
+	if err := c.dial(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) dial() error {
+	conn, err := net.Dial("unix", c.path)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.dec = json.NewDecoder(conn)
+	c.enc = json.NewEncoder(conn)
+	return nil
+}
+
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Send request and read the response, giving up once ctx is done
+func (c *Client) roundTrip(ctx context.Context, cmd string) (Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resp := Response{}
+	if c.conn == nil {
+		if err := c.dial(); err != nil {
+			return resp, err
+		}
+	}
+	conn := c.conn
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	req := Request{Cmd: cmd}
+	if cmd == CmdStart && c.Origin != nil {
+		o := c.Origin()
+		req.Origin = &o
+	}
+	err := c.enc.Encode(req)
+	if err == nil {
+		err = c.dec.Decode(&resp)
+	}
+	if err != nil {
+		// The deadline stays and a late response would answer the next
+		// request, so that one starts over on a new connection
+		stop()
+		conn.Close()
+		c.conn = nil
+		if ctx.Err() != nil {
+			return resp, ctx.Err()
+		}
+		return resp, err
+	}
+	if !resp.OK {
+		return resp, remoteError(resp.Error)
+	}
+	return resp, nil
+}
+
+// Run command and return the status after it
+func (c *Client) Do(ctx context.Context, cmd string) (models.Status, error) {
+	resp, err := c.roundTrip(ctx, cmd)
+	if err != nil {
+		return models.Status{}, err
+	}
+	if resp.Status == nil {
+		return models.Status{}, fmt.Errorf("daemon: %s: no status in response", cmd)
+	}
+	return *resp.Status, nil
+}
+
+// Subscribe calls fn for every event until ctx is done or the daemon
+// goes away. Events are read on a connection of their own, so the
+// client can still send commands.
+func (c *Client) Subscribe(ctx context.Context, fn func(Event)) error {
+	sub, err := Dial(c.path)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	if _, err := sub.roundTrip(ctx, CmdSubscribe); err != nil {
+		return err
+	}
+
+	stop := context.AfterFunc(ctx, func() { sub.conn.SetDeadline(time.Now()) })
+	defer stop()
+	for {
+		resp := Response{}
+		if err := sub.dec.Decode(&resp); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if resp.Error != "" {
+			return remoteError(resp.Error)
+		}
+		if resp.Status == nil {
+			return errors.New("daemon: event without status")
+		}
+		fn(Event{Name: resp.Event, Status: *resp.Status})
+	}
+}
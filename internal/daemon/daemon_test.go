@@ -0,0 +1,267 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/repository"
+)
+
+func waitEvent(t *testing.T, events <-chan Event, name string) Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Name == name {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("Timeout waiting for %s event", name)
+		}
+	}
+}
+
+func TestDaemon(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config, err := models.NewConfig(repository.NewInMemoryRepo(), 2*time.Second, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "pomodoro-go.sock")
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error)
+	go func() { served <- NewServer(config).Serve(ctx, l) }()
+
+	if _, err := Listen(path); !errors.Is(err, ErrDaemonRunning) {
+		t.Errorf("Expected error %q, got %v", ErrDaemonRunning, err)
+	}
+
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	events := make(chan Event, 64)
+	go c.Subscribe(ctx, func(ev Event) { events <- ev })
+	waitEvent(t, events, EventStatus)
+
+	s, err := c.Do(ctx, CmdStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Category != models.PomodoCategory || s.State != models.StateRunning {
+		t.Errorf("Expected running pomodoro, got %+v", s)
+	}
+	waitEvent(t, events, EventStart)
+
+	s, err = c.Do(ctx, CmdPause)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.State != models.StatePaused {
+		t.Errorf("Expected paused pomodoro, got %+v", s)
+	}
+	if ev := waitEvent(t, events, EventPause); ev.Status.State != models.StatePaused {
+		t.Errorf("Expected paused status in event, got %+v", ev.Status)
+	}
+	if _, err := c.Do(ctx, CmdPause); !errors.Is(err, models.ErrIntervalNotRunning) {
+		t.Errorf("Expected error %q, got %v", models.ErrIntervalNotRunning, err)
+	}
+
+	if _, err := c.Do(ctx, CmdResume); err != nil {
+		t.Fatal(err)
+	}
+	if ev := waitEvent(t, events, EventDone); ev.Status.State != models.StateDone || ev.Status.Today != 1 {
+		t.Errorf("Expected finished pomodoro, got %+v", ev.Status)
+	}
+
+	if _, err := c.Do(ctx, CmdResume); !errors.Is(err, models.ErrIntervalNotPaused) {
+		t.Errorf("Expected error %q, got %v", models.ErrIntervalNotPaused, err)
+	}
+	if _, err := c.Do(ctx, CmdStop); !errors.Is(err, models.ErrIntervalCompleted) {
+		t.Errorf("Expected error %q, got %v", models.ErrIntervalCompleted, err)
+	}
+
+	s, err = c.Do(ctx, CmdSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Category != models.ShortBreakCategory || s.State != models.StateSkipped {
+		t.Errorf("Expected skipped break, got %+v", s)
+	}
+	waitEvent(t, events, EventSkip)
+
+	if _, err := c.Do(ctx, "bogus"); !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("Expected error %q, got %v", ErrUnknownCommand, err)
+	}
+
+	// Running interval is canceled on shutdown
+	if _, err := c.Do(ctx, CmdStart); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+	i, err := config.Repo.Last(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.State != models.StateCanceled {
+		t.Errorf("Expected canceled interval after shutdown, got %s", models.StateName(i.State))
+	}
+}
+
+// Repository failing to count down once broken
+type brokenRepo struct {
+	models.Repository
+	broken atomic.Bool
+}
+
+func (r *brokenRepo) Modify(ctx context.Context, id int64, fn func(*models.Interval) error) error {
+	if r.broken.Load() {
+		return errors.New("disk full")
+	}
+	return r.Repository.Modify(ctx, id, fn)
+}
+
+func TestRunError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := &brokenRepo{Repository: repository.NewInMemoryRepo()}
+	config, err := models.NewConfig(repo, time.Minute, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(config)
+	defer s.Close()
+
+	events := make(chan Event, 64)
+	go s.Subscribe(ctx, func(ev Event) { events <- ev })
+	waitEvent(t, events, EventStatus)
+
+	if _, err := s.Do(ctx, CmdStart); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, events, EventStart)
+
+	// Subscribers hear of the timer which stopped counting down
+	repo.broken.Store(true)
+	waitEvent(t, events, EventStatus)
+}
+
+func TestClientCanceled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pomodoro-go.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// The first connection never answers, later ones answer the status
+	go func() {
+		for n := 0; ; n++ {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(n int) {
+				defer conn.Close()
+				dec, enc := json.NewDecoder(conn), json.NewEncoder(conn)
+				for {
+					req := Request{}
+					if err := dec.Decode(&req); err != nil {
+						return
+					}
+					if n > 0 {
+						enc.Encode(Response{OK: true, Status: &models.Status{ID: int64(n)}})
+					}
+				}
+			}(n)
+		}
+	}()
+
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := c.Do(ctx, CmdStatus); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected error %q, got %v", context.DeadlineExceeded, err)
+	}
+
+	s, err := c.Do(context.Background(), CmdStatus)
+	if err != nil {
+		t.Fatalf("Expected the client usable after a canceled request, got %v", err)
+	}
+	if s.ID != 1 {
+		t.Errorf("Expected status from a new connection, got %+v", s)
+	}
+}
+
+// Pomodoros started by a client record its project, task and tags
+func TestStartOrigin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := repository.NewInMemoryRepo()
+	config, err := models.NewConfig(repo, time.Minute, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Project, config.Task = "daemon", "daemon task"
+
+	path := filepath.Join(t.TempDir(), "pomodoro-go.sock")
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error)
+	go func() { served <- NewServer(config).Serve(ctx, l) }()
+	defer func() {
+		cancel()
+		<-served
+	}()
+
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Origin = func() Origin {
+		return Origin{Project: "site", Task: "write report", Tags: []string{"docs"}}
+	}
+
+	if _, err := c.Do(ctx, CmdStart); err != nil {
+		t.Fatal(err)
+	}
+	i, err := repo.Last(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Project != "site" || i.Task != "write report" || len(i.Tags) != 1 || i.Tags[0] != "docs" {
+		t.Errorf("Expected pomodoro of the client, got %+v", i)
+	}
+	if config.Project != "daemon" || config.Task != "daemon task" {
+		t.Errorf("Expected config of the daemon unchanged, got %q %q", config.Project, config.Task)
+	}
+}
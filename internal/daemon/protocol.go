@@ -0,0 +1,139 @@
+// Package daemon runs the timer in a long-lived process controlled over
+// a Unix socket, so intervals outlive the dashboard and the commands
+// that started them.
+//
+// # Protocol
+//
+// Clients connect to the socket and write requests as JSON objects, one
+// per line. Every request is answered by one response line, in order:
+//
+//	-> {"cmd":"start"}
+//	<- {"ok":true,"status":{"id":7,"category":"Pomodoro","state":1,"planned":1500000000000,"remaining":1500000000000,"today":3}}
+//	-> {"cmd":"resume"}
+//	<- {"ok":false,"error":"Interval not paused: Pomodoro Running"}
+//
+// Commands are status, start, resume, pause, stop and skip. They act
+// like the headless commands of the same name and answer with the status
+// after the change. States are numbered as in models, durations are
+// nanoseconds.
+//
+// Start may carry the origin of the client. A new pomodoro then records
+// its project, task and tags instead of those of the daemon, which runs
+// in its own directory:
+//
+//	-> {"cmd":"start","origin":{"project":"site","task":"write report","tags":["docs"]}}
+//
+// The subscribe command turns the connection into a stream of events.
+// After the response the daemon writes the current status as a "status"
+// event and then every change:
+//
+//	-> {"cmd":"subscribe"}
+//	<- {"ok":true}
+//	<- {"event":"status","status":{...}}
+//	<- {"event":"start","status":{...}}
+//	<- {"event":"tick","status":{...}}
+//
+// Events are start, tick (every second of a running interval), pause,
+// stop, skip and done. Events are dropped for subscribers which do not
+// keep up.
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Commands
+const (
+	CmdStatus    = "status"
+	CmdStart     = "start"
+	CmdResume    = "resume"
+	CmdPause     = "pause"
+	CmdStop      = "stop"
+	CmdSkip      = "skip"
+	CmdSubscribe = "subscribe"
+)
+
+// Events
+const (
+	EventStatus = "status"
+	EventStart  = "start"
+	EventTick   = "tick"
+	EventPause  = "pause"
+	EventStop   = "stop"
+	EventSkip   = "skip"
+	EventDone   = "done"
+)
+
+var (
+	ErrDaemonRunning  = fmt.Errorf("Daemon already running")
+	ErrUnknownCommand = fmt.Errorf("Unknown command")
+)
+
+type Request struct {
+	Cmd    string  `json:"cmd"`
+	Origin *Origin `json:"origin,omitempty"`
+}
+
+// Origin is the context of the client recorded on the intervals it
+// starts
+type Origin struct {
+	Project string   `json:"project,omitempty"`
+	Task    string   `json:"task,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+// Response to a request or event of a subscription
+type Response struct {
+	OK     bool           `json:"ok,omitempty"`
+	Error  string         `json:"error,omitempty"`
+	Event  string         `json:"event,omitempty"`
+	Status *models.Status `json:"status,omitempty"`
+}
+
+type Event struct {
+	Name   string
+	Status models.Status
+}
+
+// Timer runs the intervals, implemented by the server in process and
+// by the client of a daemon
+type Timer interface {
+	Do(ctx context.Context, cmd string) (models.Status, error)
+	Subscribe(ctx context.Context, fn func(Event)) error
+}
+
+// Return default socket path, under $XDG_RUNTIME_DIR when set
+func SocketPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("pomodoro-go-%d", os.Getuid()))
+	}
+	return filepath.Join(dir, "pomodoro-go.sock")
+}
+
+// Errors callers check with errors.Is
+var knownErrors = []error{
+	models.ErrNoIntervals,
+	models.ErrIntervalNotRunning,
+	models.ErrIntervalNotPaused,
+	models.ErrIntervalCompleted,
+	models.ErrInvalidState,
+	models.ErrInvalidID,
+	ErrUnknownCommand,
+}
+
+// Turn error message of a response back into a wrapped known error
+func remoteError(msg string) error {
+	for _, e := range knownErrors {
+		if rest, ok := strings.CutPrefix(msg, e.Error()); ok {
+			return fmt.Errorf("%w%s", e, rest)
+		}
+	}
+	return fmt.Errorf("daemon: %s", msg)
+}
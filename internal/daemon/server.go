@@ -0,0 +1,390 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Events buffered for each subscriber
+const subscriberBuffer = 16
+
+// Listen on the Unix socket at path, replacing a stale socket left
+// over by a daemon which did not shut down
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: %s", ErrDaemonRunning, path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Interval ticking in the daemon
+type run struct {
+	id     int64
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Stop ticking and wait until the final event is published
+func (r *run) stop() {
+	r.cancel()
+	<-r.done
+}
+
+// Server owns the timer and serves the protocol to clients
+type Server struct {
+	config *models.IntervalConfig
+
+	// Lifetime of the running intervals
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Serializes commands and guards current
+	mu      sync.Mutex
+	current *run
+
+	subsMu sync.Mutex
+	subs   map[chan Event]struct{}
+
+	wg sync.WaitGroup
+}
+
+func NewServer(config *models.IntervalConfig) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		config: config,
+		ctx:    ctx,
+		cancel: cancel,
+		subs:   map[chan Event]struct{}{},
+	}
+}
+
+// Close cancels the running interval, as when the dashboard quits
+func (s *Server) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// Serve accepts connections on l until ctx is done and closes the
+// server then
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { l.Close() })
+	defer stop()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				s.Close()
+				return nil
+			}
+			return err
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+func (s *Server) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		req := Request{}
+		if err := dec.Decode(&req); err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				enc.Encode(Response{Error: fmt.Sprintf("invalid request: %v", err)})
+			}
+			return
+		}
+
+		if req.Cmd == CmdSubscribe {
+			s.subscribe(ctx, conn, enc)
+			return
+		}
+
+		resp := Response{OK: true}
+		var st models.Status
+		var err error
+		if req.Cmd == CmdStart && req.Origin != nil {
+			st, err = s.start(ctx, false, req.Origin)
+		} else {
+			st, err = s.Do(ctx, req.Cmd)
+		}
+		if err != nil {
+			resp = Response{Error: err.Error()}
+		} else {
+			resp.Status = &st
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// Run command and return the status after it, as the client does over
+// the socket
+func (s *Server) Do(ctx context.Context, cmd string) (models.Status, error) {
+	switch cmd {
+	case CmdStatus:
+		return models.CurrentStatus(ctx, s.config, time.Now())
+	case CmdStart:
+		return s.start(ctx, false, nil)
+	case CmdResume:
+		return s.start(ctx, true, nil)
+	case CmdPause, CmdStop, CmdSkip:
+		return s.change(ctx, cmd)
+	}
+	return models.Status{}, fmt.Errorf("%w: %q", ErrUnknownCommand, cmd)
+}
+
+// Start the next interval or resume the paused one, a new one records
+// origin when set
+func (s *Server) start(ctx context.Context, resume bool, origin *Origin) (models.Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var i models.Interval
+	var err error
+	if resume {
+		i, err = s.config.Repo.Last(ctx)
+		if err == nil && i.State != models.StatePaused {
+			err = fmt.Errorf("%w: %s %s", models.ErrIntervalNotPaused,
+				i.Category, models.StateName(i.State))
+		}
+	} else {
+		config := s.config
+		if origin != nil {
+			c := *s.config
+			c.Project, c.Task, c.Tags = origin.Project, origin.Task, origin.Tags
+			// The client picked the task already
+			c.Picked = nil
+			config = &c
+		}
+		i, err = models.GetInterval(ctx, config)
+	}
+	if err != nil {
+		return models.Status{}, err
+	}
+
+	// Ticking here or in another process
+	if i.State != models.StateRunning {
+		// Let a previous run notice it was paused elsewhere
+		if s.current != nil {
+			s.current.stop()
+		}
+
+		runCtx, cancel := context.WithCancel(s.ctx)
+		s.current = &run{id: i.ID, cancel: cancel, done: make(chan struct{})}
+		started := make(chan error, 1)
+		s.wg.Add(1)
+		go s.run(runCtx, s.current, i, started)
+		if err := <-started; err != nil {
+			return models.Status{}, err
+		}
+	}
+	return models.CurrentStatus(ctx, s.config, time.Now())
+}
+
+// Tick the interval until it is finished, paused or stopped, runCtx
+// stops ticking without canceling the interval
+func (s *Server) run(runCtx context.Context, r *run, i models.Interval, started chan<- error) {
+	ctx := s.ctx
+	defer s.wg.Done()
+	defer close(r.done)
+	defer r.cancel()
+
+	// Callbacks run on this goroutine, started is read once
+	ended, sent := false, false
+	start := func(models.Interval) {
+		sent = true
+		started <- nil
+		s.publish(ctx, EventStart)
+	}
+	periodic := func(models.Interval) {
+		s.publish(ctx, EventTick)
+	}
+	end := func(models.Interval) {
+		ended = true
+		s.publish(ctx, EventDone)
+	}
+
+	if err := i.Start(runCtx, s.config, start, periodic, end); err != nil {
+		// Failed before it started
+		if !sent {
+			started <- err
+			return
+		}
+		log.Printf("%s %d: %v", i.Category, i.ID, err)
+	}
+	if ended || ctx.Err() != nil {
+		return
+	}
+
+	// Paused, stopped or skipped
+	cur, err := s.config.Repo.ByID(ctx, i.ID)
+	if err != nil {
+		log.Printf("%s %d: %v", i.Category, i.ID, err)
+		return
+	}
+	switch cur.State {
+	case models.StatePaused:
+		s.publish(ctx, EventPause)
+	case models.StateCanceled:
+		s.publish(ctx, EventStop)
+	case models.StateSkipped:
+		s.publish(ctx, EventSkip)
+	case models.StateDone:
+		// Ran out ticking in another process
+		s.publish(ctx, EventDone)
+	default:
+		// Failed while ticking, it is not counted down anymore
+		s.publish(ctx, EventStatus)
+	}
+}
+
+// Pause, stop or skip the current interval
+func (s *Server) change(ctx context.Context, cmd string) (models.Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var i models.Interval
+	var err error
+	var event string
+	switch cmd {
+	case CmdPause:
+		event = EventPause
+		if i, err = s.config.Repo.Last(ctx); err == nil {
+			err = i.Pause(ctx, s.config)
+		}
+	case CmdStop:
+		event = EventStop
+		if i, err = s.config.Repo.Last(ctx); err == nil {
+			err = i.Stop(ctx, s.config)
+		}
+	case CmdSkip:
+		event = EventSkip
+		if i, err = models.GetInterval(ctx, s.config); err == nil {
+			err = i.Skip(ctx, s.config)
+		}
+	}
+	if err != nil {
+		return models.Status{}, err
+	}
+
+	// The ticking interval announces the change itself
+	if s.current != nil && s.current.id == i.ID {
+		s.current.stop()
+		s.current = nil
+	} else {
+		s.publish(ctx, event)
+	}
+	return models.CurrentStatus(ctx, s.config, time.Now())
+}
+
+func (s *Server) publish(ctx context.Context, name string) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	if len(s.subs) == 0 {
+		return
+	}
+
+	st, err := models.CurrentStatus(context.WithoutCancel(ctx), s.config, time.Now())
+	if err != nil {
+		log.Printf("%s event: %v", name, err)
+		return
+	}
+	for ch := range s.subs {
+		select {
+		case ch <- Event{Name: name, Status: st}:
+		default:
+		}
+	}
+}
+
+// Subscribe calls fn with the current status and then every event until
+// ctx is done
+func (s *Server) Subscribe(ctx context.Context, fn func(Event)) error {
+	ch := make(chan Event, subscriberBuffer)
+	s.subsMu.Lock()
+	s.subs[ch] = struct{}{}
+	s.subsMu.Unlock()
+	defer func() {
+		s.subsMu.Lock()
+		delete(s.subs, ch)
+		s.subsMu.Unlock()
+	}()
+
+	st, err := models.CurrentStatus(ctx, s.config, time.Now())
+	if err != nil {
+		return err
+	}
+	fn(Event{Name: EventStatus, Status: st})
+
+	for {
+		select {
+		case ev := <-ch:
+			fn(ev)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Stream events to conn until the client goes away
+func (s *Server) subscribe(ctx context.Context, conn net.Conn, enc *json.Encoder) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		io.Copy(io.Discard, conn)
+		cancel()
+	}()
+
+	if err := enc.Encode(Response{OK: true}); err != nil {
+		return
+	}
+	err := s.Subscribe(ctx, func(ev Event) {
+		if err := enc.Encode(Response{Event: ev.Name, Status: &ev.Status}); err != nil {
+			cancel()
+		}
+	})
+	if err != nil {
+		enc.Encode(Response{Error: err.Error()})
+	}
+}
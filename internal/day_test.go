@@ -0,0 +1,124 @@
+package internal_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+func TestParseDayStart(t *testing.T) {
+	testCases := []struct {
+		input  string
+		expect time.Duration
+		err    error
+	}{
+		{"", 0, nil},
+		{"04:00", 4 * time.Hour, nil},
+		{"4h30m", 4*time.Hour + 30*time.Minute, nil},
+		{"24h", 0, models.ErrInvalidDayStart},
+		{"-1h", 0, models.ErrInvalidDayStart},
+		{"noon", 0, models.ErrInvalidDayStart},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			d, err := models.ParseDayStart(tc.input)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Expected error %v, got %v", tc.err, err)
+			}
+			if d != tc.expect {
+				t.Errorf("Expected %s, got %s", tc.expect, d)
+			}
+		})
+	}
+}
+
+func TestDayBounds(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	config := &models.IntervalConfig{Location: berlin, DayStart: 4 * time.Hour}
+
+	testCases := []struct {
+		name     string
+		input    time.Time
+		expStart time.Time
+		expEnd   time.Time
+	}{
+		{
+			name:     "AfterMidnight",
+			input:    time.Date(2023, 9, 2, 1, 30, 0, 0, berlin),
+			expStart: time.Date(2023, 9, 1, 4, 0, 0, 0, berlin),
+			expEnd:   time.Date(2023, 9, 2, 4, 0, 0, 0, berlin),
+		},
+		{
+			name:     "OtherZone",
+			input:    time.Date(2023, 9, 2, 3, 0, 0, 0, time.UTC),
+			expStart: time.Date(2023, 9, 2, 4, 0, 0, 0, berlin),
+			expEnd:   time.Date(2023, 9, 3, 4, 0, 0, 0, berlin),
+		},
+		{
+			name:     "DaylightSaving",
+			input:    time.Date(2023, 10, 29, 12, 0, 0, 0, berlin),
+			expStart: time.Date(2023, 10, 29, 4, 0, 0, 0, berlin),
+			expEnd:   time.Date(2023, 10, 30, 4, 0, 0, 0, berlin),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end := config.DayBounds(tc.input)
+			if !start.Equal(tc.expStart) || !end.Equal(tc.expEnd) {
+				t.Errorf("Expected [%s, %s), got [%s, %s)",
+					tc.expStart, tc.expEnd, start, end)
+			}
+		})
+	}
+}
+
+func TestDailySummaryDayStart(t *testing.T) {
+	ctx := context.Background()
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config, err := models.NewConfig(repo, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Location = time.FixedZone("UTC+2", 2*60*60)
+	config.DayStart = 4 * time.Hour
+
+	day := time.Date(2023, 9, 1, 12, 0, 0, 0, config.Location)
+	for _, i := range []models.Interval{
+		{Category: models.PomodoCategory, TimeStart: day, TimeActual: time.Minute},
+		// Past midnight, before the day start
+		{Category: models.PomodoCategory, TimeStart: day.Add(14 * time.Hour), TimeActual: 2 * time.Minute},
+		{Category: models.ShortBreakCategory, TimeStart: day.Add(14 * time.Hour), TimeActual: 3 * time.Minute},
+		{Category: models.PomodoCategory, TimeStart: day.Add(16 * time.Hour), TimeActual: 4 * time.Minute},
+	} {
+		if _, err := repo.Create(ctx, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ds, err := models.DailySummary(ctx, day.Add(13*time.Hour), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds[0] != 3*time.Minute || ds[1] != 3*time.Minute {
+		t.Errorf("Expected [3m0s 3m0s], got %v", ds)
+	}
+
+	ws, err := models.RangeSummary(ctx, day.Add(17*time.Hour), 2, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws[0].Labels[0] != "02/Sep" || ws[0].Values[0] != 240 {
+		t.Errorf("Expected 240s on 02/Sep, got %v on %s", ws[0].Values[0], ws[0].Labels[0])
+	}
+	if ws[0].Labels[1] != "01/Sep" || ws[0].Values[1] != 180 {
+		t.Errorf("Expected 180s on 01/Sep, got %v on %s", ws[0].Values[1], ws[0].Labels[1])
+	}
+}
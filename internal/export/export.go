@@ -0,0 +1,230 @@
+// Package export writes intervals in formats understood by spreadsheets
+// and calendars. Writers encode one interval at a time, so histories of
+// any size can be streamed from models.Repository.Range.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatICS  = "ics"
+)
+
+var ErrInvalidFormat = fmt.Errorf("Invalid export format")
+
+// Writer encodes intervals, Close completes the output
+type Writer interface {
+	Write(i models.Interval) error
+	Close() error
+}
+
+// Return writer of format to w
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatJSON:
+		return newJSONWriter(w), nil
+	case FormatICS:
+		return newICSWriter(w)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrInvalidFormat, format)
+}
+
+// Interval as written to CSV and JSON
+type record struct {
+	ID             int64     `json:"id"`
+	Category       string    `json:"category"`
+	State          string    `json:"state"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	PlannedSeconds int64     `json:"planned_seconds"`
+	ActualSeconds  int64     `json:"actual_seconds"`
+	Task           string    `json:"task"`
+	Tags           []string  `json:"tags"`
+	Project        string    `json:"project"`
+}
+
+func newRecord(i models.Interval) record {
+	tags := i.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return record{
+		ID:             i.ID,
+		Category:       i.Category,
+		State:          models.StateName(i.State),
+		Start:          i.TimeStart.UTC().Truncate(time.Second),
+		End:            i.TimeStart.Add(i.TimeActual).UTC().Truncate(time.Second),
+		PlannedSeconds: int64(i.TimePlanning.Seconds()),
+		ActualSeconds:  int64(i.TimeActual.Seconds()),
+		Task:           i.Task,
+		Tags:           tags,
+		Project:        i.Project,
+	}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	c := &csvWriter{w: csv.NewWriter(w)}
+	err := c.w.Write([]string{"id", "category", "state", "start", "end",
+		"planned_seconds", "actual_seconds", "task", "tags", "project"})
+	return c, err
+}
+
+func (c *csvWriter) Write(i models.Interval) error {
+	r := newRecord(i)
+	return c.w.Write([]string{
+		strconv.FormatInt(r.ID, 10),
+		r.Category,
+		r.State,
+		r.Start.Format(time.RFC3339),
+		r.End.Format(time.RFC3339),
+		strconv.FormatInt(r.PlannedSeconds, 10),
+		strconv.FormatInt(r.ActualSeconds, 10),
+		r.Task,
+		strings.Join(r.Tags, ","),
+		r.Project,
+	})
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonWriter writes a JSON array, one element per line
+type jsonWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func newJSONWriter(w io.Writer) *jsonWriter {
+	return &jsonWriter{w: bufio.NewWriter(w)}
+}
+
+func (j *jsonWriter) Write(i models.Interval) error {
+	data, err := json.Marshal(newRecord(i))
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if j.count == 0 {
+		sep = "[\n"
+	}
+	j.count++
+
+	if _, err := j.w.WriteString(sep); err != nil {
+		return err
+	}
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	end := "\n]\n"
+	if j.count == 0 {
+		end = "[]\n"
+	}
+	if _, err := j.w.WriteString(end); err != nil {
+		return err
+	}
+	return j.w.Flush()
+}
+
+// icsWriter writes an iCalendar with a VEVENT per interval (RFC 5545)
+type icsWriter struct {
+	w     *bufio.Writer
+	stamp string
+}
+
+const icsTime = "20060102T150405Z"
+
+func newICSWriter(w io.Writer) (*icsWriter, error) {
+	c := &icsWriter{
+		w:     bufio.NewWriter(w),
+		stamp: time.Now().UTC().Format(icsTime),
+	}
+	err := c.lines(
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//xor111xor//pomodoro-go//EN",
+		"CALSCALE:GREGORIAN",
+	)
+	return c, err
+}
+
+func (c *icsWriter) Write(i models.Interval) error {
+	summary := i.Category
+	if i.Task != "" {
+		summary += ": " + i.Task
+	}
+	categories := append([]string{i.Category}, i.Tags...)
+	for n := range categories {
+		categories[n] = icsEscape(categories[n])
+	}
+
+	return c.lines(
+		"BEGIN:VEVENT",
+		fmt.Sprintf("UID:%d@pomodoro-go", i.ID),
+		"DTSTAMP:"+c.stamp,
+		"DTSTART:"+i.TimeStart.UTC().Format(icsTime),
+		"DTEND:"+i.TimeStart.Add(i.TimeActual).UTC().Format(icsTime),
+		"SUMMARY:"+icsEscape(summary),
+		"CATEGORIES:"+strings.Join(categories, ","),
+		"DESCRIPTION:"+icsEscape(fmt.Sprintf("%s, planned %s, actual %s",
+			models.StateName(i.State), i.TimePlanning, i.TimeActual)),
+		"END:VEVENT",
+	)
+}
+
+func (c *icsWriter) Close() error {
+	if err := c.lines("END:VCALENDAR"); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// Write content lines folded at 75 octets
+func (c *icsWriter) lines(lines ...string) error {
+	for _, l := range lines {
+		// Continuation lines start with a space
+		for width := 75; len(l) > width; width = 74 {
+			// Do not split UTF-8 sequences
+			n := width
+			for n > 0 && l[n]&0xc0 == 0x80 {
+				n--
+			}
+			if _, err := c.w.WriteString(l[:n] + "\r\n "); err != nil {
+				return err
+			}
+			l = l[n:]
+		}
+		if _, err := c.w.WriteString(l + "\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func icsEscape(s string) string {
+	return icsEscaper.Replace(s)
+}
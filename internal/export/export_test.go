@@ -0,0 +1,122 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+var intervals = []models.Interval{
+	{
+		ID:           1,
+		Category:     models.PomodoCategory,
+		State:        models.StateDone,
+		TimeStart:    time.Date(2023, 9, 4, 10, 0, 0, 0, time.UTC),
+		TimePlanning: 25 * time.Minute,
+		TimeActual:   25 * time.Minute,
+		Task:         "Write the report, part 1; " + strings.Repeat("long ", 20),
+		Tags:         []string{"work", "docs"},
+		Project:      "site",
+	},
+	{
+		ID:           2,
+		Category:     models.ShortBreakCategory,
+		State:        models.StateCanceled,
+		TimeStart:    time.Date(2023, 9, 4, 10, 25, 0, 0, time.UTC),
+		TimePlanning: 5 * time.Minute,
+		TimeActual:   time.Minute,
+	},
+}
+
+func write(t *testing.T, format string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range intervals {
+		if err := w.Write(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestCSV(t *testing.T) {
+	rows, err := csv.NewReader(strings.NewReader(write(t, FormatCSV))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected header and 2 rows, got %d", len(rows))
+	}
+	exp := []string{"1", "Pomodoro", "Done", "2023-09-04T10:00:00Z", "2023-09-04T10:25:00Z",
+		"1500", "1500", intervals[0].Task, "work,docs", "site"}
+	if strings.Join(rows[1], "|") != strings.Join(exp, "|") {
+		t.Errorf("Expected %q, got %q", exp, rows[1])
+	}
+}
+
+func TestJSON(t *testing.T) {
+	got := []record{}
+	if err := json.Unmarshal([]byte(write(t, FormatJSON)), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(got))
+	}
+	if got[1].State != "Canceled" || got[1].ActualSeconds != 60 || len(got[1].Tags) != 0 {
+		t.Errorf("Unexpected record %+v", got[1])
+	}
+
+	var buf bytes.Buffer
+	w, _ := NewWriter(FormatJSON, &buf)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[]\n" {
+		t.Errorf("Expected empty array, got %q", buf.String())
+	}
+}
+
+func TestICS(t *testing.T) {
+	out := write(t, FormatICS)
+
+	for _, l := range strings.Split(out, "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("Line longer than 75 octets: %q", l)
+		}
+	}
+	unfolded := strings.ReplaceAll(out, "\r\n ", "")
+	for _, exp := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"DTSTART:20230904T100000Z\r\nDTEND:20230904T102500Z\r\n",
+		`SUMMARY:Pomodoro: Write the report\, part 1\; long`,
+		"CATEGORIES:Pomodoro,work,docs\r\n",
+		"UID:2@pomodoro-go\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(unfolded, exp) {
+			t.Errorf("Expected %q in output:\n%s", exp, out)
+		}
+	}
+	if n := strings.Count(out, "BEGIN:VEVENT"); n != 2 {
+		t.Errorf("Expected 2 events, got %d", n)
+	}
+}
+
+func TestInvalidFormat(t *testing.T) {
+	if _, err := NewWriter("xml", &bytes.Buffer{}); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected error %q, got %v", ErrInvalidFormat, err)
+	}
+}
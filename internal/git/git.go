@@ -0,0 +1,106 @@
+// Package git reads the HEAD and history of a working tree through the
+// git command, to link pomodoros to the commits made during them.
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+var ErrNotRepository = errors.New("not a git repository")
+
+// Repo is a git working tree, a models.GitRepo
+type Repo struct {
+	dir string
+}
+
+// Commit of the history
+type Commit struct {
+	Hash    string
+	Time    time.Time
+	Author  string
+	Subject string
+}
+
+// Open the working tree containing dir
+func Open(dir string) (*Repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, err
+	}
+	out, err := run(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		// Bare repositories and .git directories have no working tree
+		return nil, fmt.Errorf("%w: %s", ErrNotRepository, dir)
+	}
+	return &Repo{dir: strings.TrimSpace(out)}, nil
+}
+
+// Top directory of the working tree
+func (r *Repo) Dir() string {
+	return r.dir
+}
+
+// Current commit, empty before the first one
+func (r *Repo) Head() (string, error) {
+	out, err := run(r.dir, "rev-parse", "-q", "--verify", "HEAD^{commit}")
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 1 && out == "" {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Commits reachable from to and not from, newest first. An empty from
+// starts at the first commit. Commits committed before since, like
+// those of a branch checked out in between, are left out.
+func (r *Repo) Log(from, to string, since time.Time) ([]Commit, error) {
+	rev := to
+	if from != "" {
+		rev = from + ".." + to
+	}
+	out, err := run(r.dir, "log", "--format=%H%x00%cI%x00%an%x00%s",
+		"--since="+since.Format(time.RFC3339), rev, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []Commit{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line == "" {
+			continue
+		}
+		f := strings.SplitN(line, "\x00", 4)
+		if len(f) != 4 {
+			return nil, fmt.Errorf("git log: unexpected line %q", line)
+		}
+		t, err := time.Parse(time.RFC3339, f[1])
+		if err != nil {
+			return nil, fmt.Errorf("git log: %w", err)
+		}
+		commits = append(commits, Commit{Hash: f[0], Time: t, Author: f[2], Subject: f[3]})
+	}
+	return commits, nil
+}
+
+// Run git in dir, errors carry its message
+func run(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.String(), fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return stdout.String(), fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,114 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Run git in dir for the test with fixed identities and dates
+func gitAt(t *testing.T, dir string, date time.Time, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=Ann", "GIT_AUTHOR_EMAIL=ann@example.com",
+		"GIT_COMMITTER_NAME=Ann", "GIT_COMMITTER_EMAIL=ann@example.com",
+		"GIT_AUTHOR_DATE="+date.Format(time.RFC3339),
+		"GIT_COMMITTER_DATE="+date.Format(time.RFC3339))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	start := time.Date(2023, 9, 6, 10, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+
+	if _, err := Open(dir); !errors.Is(err, ErrNotRepository) {
+		t.Fatalf("Expected %q, got %v", ErrNotRepository, err)
+	}
+
+	gitAt(t, dir, start, "init", "-q", "-b", "main")
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Open(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top, _ := filepath.EvalSymlinks(dir); r.Dir() != top {
+		t.Errorf("Expected top directory %q, got %q", top, r.Dir())
+	}
+
+	head, err := r.Head()
+	if err != nil || head != "" {
+		t.Fatalf("Expected no HEAD before the first commit, got %q, %v", head, err)
+	}
+
+	commit := func(at time.Time, subject string) string {
+		gitAt(t, dir, at, "commit", "-q", "--allow-empty", "-m", subject)
+		return gitAt(t, dir, at, "rev-parse", "HEAD")
+	}
+	first := commit(start.Add(-time.Hour), "Before")
+	// Branch committed earlier, checked out during the pomodoro
+	gitAt(t, dir, start, "checkout", "-q", "-b", "old")
+	commit(start.Add(-30*time.Minute), "Old work")
+	gitAt(t, dir, start, "checkout", "-q", "main")
+	commit(start.Add(5*time.Minute), "Add parser")
+	gitAt(t, dir, start, "merge", "-q", "--no-ff", "--no-edit", "old")
+	gitAt(t, dir, start.Add(10*time.Minute), "commit", "-q", "--amend", "-m", "Merge old")
+	last := commit(start.Add(20*time.Minute), "Fix parser")
+
+	head, err = r.Head()
+	if err != nil || head != last {
+		t.Fatalf("Expected HEAD %s, got %q, %v", last, head, err)
+	}
+
+	testCases := []struct {
+		name string
+		from string
+		exp  string
+	}{
+		{"Range", first, "Fix parser,Merge old,Add parser"},
+		{"First commit", "", "Fix parser,Merge old,Add parser"},
+		{"Nothing", last, ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			commits, err := r.Log(tc.from, last, start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			subjects := []string{}
+			for _, c := range commits {
+				subjects = append(subjects, c.Subject)
+			}
+			if got := strings.Join(subjects, ","); got != tc.exp {
+				t.Errorf("Expected %q, got %q", tc.exp, got)
+			}
+		})
+	}
+
+	commits, err := r.Log(first, last, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := commits[0]; c.Hash != last || c.Author != "Ann" || !c.Time.Equal(start.Add(20*time.Minute)) {
+		t.Errorf("Unexpected commit %+v", c)
+	}
+
+	if _, err := r.Log("0000000", last, start); err == nil {
+		t.Error("Expected error for unknown revision")
+	}
+}
@@ -0,0 +1,125 @@
+package internal_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Working tree whose HEAD is set by the test
+type gitRepo struct {
+	dir string
+
+	mu   sync.Mutex
+	head string
+	err  error
+}
+
+func (g *gitRepo) Dir() string {
+	return g.dir
+}
+
+func (g *gitRepo) Head() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.head, g.err
+}
+
+func (g *gitRepo) set(head string, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.head, g.err = head, err
+}
+
+func TestRecordHead(t *testing.T) {
+	ctx := context.Background()
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config, err := models.NewConfig(repo, time.Second, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &gitRepo{dir: "/src/pomodoro", head: "aaa"}
+	config.Git = g
+	var warnings []error
+	config.Warn = func(err error) { warnings = append(warnings, err) }
+
+	check := func(id int64, start, end string) {
+		t.Helper()
+		i, err := repo.ByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.GitDir != g.dir || i.HeadStart != start || i.HeadEnd != end {
+			t.Errorf("Expected %s %q..%q, got %q %q..%q", g.dir, start, end,
+				i.GitDir, i.HeadStart, i.HeadEnd)
+		}
+	}
+
+	// Committed during the pomodoro
+	noop := func(models.Interval) {}
+	i, err := models.GetInterval(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := func(models.Interval) { g.set("bbb", nil) }
+	if err := i.Start(ctx, config, start, noop, noop); err != nil {
+		t.Fatal(err)
+	}
+	check(i.ID, "aaa", "bbb")
+
+	// Skipped before starting
+	i, err = models.GetInterval(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Skip(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	check(i.ID, "bbb", "bbb")
+
+	// Stopped from another working tree
+	i, err = models.GetInterval(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- i.Start(runCtx, config, noop, noop, noop) }()
+	time.Sleep(100 * time.Millisecond)
+	other := *config
+	other.Git = &gitRepo{dir: "/src/other", head: "ccc"}
+	if err := i.Stop(ctx, &other); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	check(i.ID, "bbb", "")
+
+	// HEAD can not be read
+	failure := errors.New("git rev-parse: exit status 128")
+	g.set("", failure)
+	i, err = models.GetInterval(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Skip(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	if i, err = repo.ByID(ctx, i.ID); err != nil {
+		t.Fatal(err)
+	}
+	if i.GitDir != "" || i.HeadStart != "" || i.HeadEnd != "" {
+		t.Errorf("Expected nothing recorded, got %q %q..%q", i.GitDir, i.HeadStart, i.HeadEnd)
+	}
+	if len(warnings) != 2 || !errors.Is(warnings[0], failure) {
+		t.Errorf("Expected warnings about HEAD, got %v", warnings)
+	}
+}
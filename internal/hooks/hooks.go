@@ -0,0 +1,199 @@
+// Package hooks runs user-defined shell commands on interval transitions.
+//
+// Commands run in the background through sh -c (cmd /C on Windows) with
+// the fields of the interval in POMODORO_* environment variables, see
+// Env. Their output goes to the log and failures to an error callback,
+// so a broken hook never stops the timer.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Events
+const (
+	EventPomodoroStart = "pomodoro_start"
+	EventBreakStart    = "break_start"
+	EventPause         = "pause"
+	EventComplete      = "complete"
+	EventSkip          = "skip"
+	EventCancel        = "cancel"
+)
+
+const (
+	DefaultTimeout = 10 * time.Second
+
+	// Wait for the output of commands left behind by a killed hook
+	waitDelay = time.Second
+)
+
+// Config is the hooks section of the config, commands of an event run
+// one after another
+type Config struct {
+	PomodoroStart []string `mapstructure:"pomodoro_start"`
+	BreakStart    []string `mapstructure:"break_start"`
+	Pause         []string `mapstructure:"pause"`
+	Complete      []string `mapstructure:"complete"`
+	Skip          []string `mapstructure:"skip"`
+	Cancel        []string `mapstructure:"cancel"`
+	// Limit of each command, zero for DefaultTimeout
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Is any command configured
+func (c Config) Empty() bool {
+	return len(c.PomodoroStart)+len(c.BreakStart)+len(c.Pause)+
+		len(c.Complete)+len(c.Skip)+len(c.Cancel) == 0
+}
+
+func (c Config) commands(event string) []string {
+	switch event {
+	case EventPomodoroStart:
+		return c.PomodoroStart
+	case EventBreakStart:
+		return c.BreakStart
+	case EventPause:
+		return c.Pause
+	case EventComplete:
+		return c.Complete
+	case EventSkip:
+		return c.Skip
+	case EventCancel:
+		return c.Cancel
+	}
+	return nil
+}
+
+// Return event of the transition, empty for none. Resuming starts the
+// interval again.
+func Event(from int, i models.Interval) string {
+	switch i.State {
+	case models.StateRunning:
+		if i.Category == models.PomodoCategory {
+			return EventPomodoroStart
+		}
+		return EventBreakStart
+	case models.StatePaused:
+		return EventPause
+	case models.StateDone:
+		return EventComplete
+	case models.StateSkipped:
+		return EventSkip
+	case models.StateCanceled:
+		return EventCancel
+	}
+	return ""
+}
+
+// Env returns the variables describing the transition to the commands
+func Env(event string, from int, i models.Interval) []string {
+	return []string{
+		"POMODORO_EVENT=" + event,
+		"POMODORO_ID=" + strconv.FormatInt(i.ID, 10),
+		"POMODORO_CATEGORY=" + i.Category,
+		"POMODORO_STATE=" + models.StateName(i.State),
+		"POMODORO_PREVIOUS_STATE=" + models.StateName(from),
+		"POMODORO_TASK=" + i.Task,
+		"POMODORO_TAGS=" + strings.Join(i.Tags, ","),
+		"POMODORO_PROJECT=" + i.Project,
+		"POMODORO_START=" + i.TimeStart.Format(time.RFC3339),
+		"POMODORO_PLANNED=" + strconv.FormatInt(int64(i.TimePlanning.Seconds()), 10),
+		"POMODORO_ACTUAL=" + strconv.FormatInt(int64(i.TimeActual.Seconds()), 10),
+	}
+}
+
+// Runner runs the hooks of the transitions it observes
+type Runner struct {
+	config  Config
+	log     *log.Logger
+	onError func(error)
+	wg      sync.WaitGroup
+}
+
+// New runner logging to out, failures also go to onError
+func New(config Config, out io.Writer, onError func(error)) *Runner {
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+	return &Runner{
+		config:  config,
+		log:     log.New(out, "", log.LstdFlags),
+		onError: onError,
+	}
+}
+
+// Transition starts the commands of the event of the transition
+func (r *Runner) Transition(ctx context.Context, from int, i models.Interval) {
+	event := Event(from, i)
+	commands := r.config.commands(event)
+	if len(commands) == 0 {
+		return
+	}
+
+	// Hooks of a canceled interval run after quitting too
+	ctx = context.WithoutCancel(ctx)
+	env := append(os.Environ(), Env(event, from, i)...)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for _, command := range commands {
+			if err := r.run(ctx, event, command, env); err != nil {
+				r.onError(fmt.Errorf("hook %s: %q: %w", event, command, err))
+			}
+		}
+	}()
+}
+
+func (r *Runner) run(ctx context.Context, event, command string, env []string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+
+	cmd := shell(ctx, command)
+	cmd.Env = env
+	cmd.WaitDelay = waitDelay
+	out := &bytes.Buffer{}
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	start := time.Now()
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", r.config.Timeout)
+	}
+
+	status := "ok"
+	if err != nil {
+		status = err.Error()
+	}
+	r.log.Printf("%s: %q: %s in %s", event, command, status, time.Since(start).Round(time.Millisecond))
+	if output := strings.TrimRight(out.String(), "\n"); output != "" {
+		r.log.Printf("%s: %q output:\n%s", event, command, output)
+	}
+	return err
+}
+
+// Wait until the started commands are finished
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+func shell(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,131 @@
+//go:build !windows
+
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Collects errors of the hooks
+type errs struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (e *errs) add(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.errs = append(e.errs, err)
+}
+
+func TestRunner(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, "env")
+
+	config := Config{
+		PomodoroStart: []string{`env | grep ^POMODORO_ | sort > "$OUT"`},
+		Complete:      []string{`echo first >> "$OUT"`, `echo second >> "$OUT"`},
+		Cancel:        []string{"echo broken; exit 3"},
+		Pause:         []string{"sleep 5"},
+		Timeout:       200 * time.Millisecond,
+	}
+	t.Setenv("OUT", envFile)
+	log := &bytes.Buffer{}
+	e := &errs{}
+	r := New(config, log, e.add)
+
+	i := models.Interval{
+		ID:           7,
+		Category:     models.PomodoCategory,
+		State:        models.StateRunning,
+		TimeStart:    time.Date(2023, 9, 1, 10, 0, 0, 0, time.UTC),
+		TimePlanning: 25 * time.Minute,
+		Task:         "Write report",
+		Tags:         []string{"work", "docs"},
+		Project:      "site",
+	}
+
+	t.Run("Env", func(t *testing.T) {
+		r.Transition(ctx, models.StateNotStarted, i)
+		r.Wait()
+		data, err := os.ReadFile(envFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := strings.Join([]string{
+			"POMODORO_ACTUAL=0",
+			"POMODORO_CATEGORY=Pomodoro",
+			"POMODORO_EVENT=pomodoro_start",
+			"POMODORO_ID=7",
+			"POMODORO_PLANNED=1500",
+			"POMODORO_PREVIOUS_STATE=NotStarted",
+			"POMODORO_PROJECT=site",
+			"POMODORO_START=2023-09-01T10:00:00Z",
+			"POMODORO_STATE=Running",
+			"POMODORO_TAGS=work,docs",
+			"POMODORO_TASK=Write report",
+		}, "\n") + "\n"
+		if string(data) != exp {
+			t.Errorf("Expected environment\n%s\ngot\n%s", exp, data)
+		}
+	})
+
+	t.Run("Order", func(t *testing.T) {
+		os.Remove(envFile)
+		i.State = models.StateDone
+		r.Transition(ctx, models.StateRunning, i)
+		r.Wait()
+		data, err := os.ReadFile(envFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "first\nsecond\n" {
+			t.Errorf("Expected commands in order, got %q", data)
+		}
+	})
+
+	t.Run("NoHook", func(t *testing.T) {
+		i.Category = models.ShortBreakCategory
+		i.State = models.StateRunning
+		r.Transition(ctx, models.StateNotStarted, i)
+		r.Wait()
+		if len(e.errs) != 0 {
+			t.Errorf("Expected no errors, got %v", e.errs)
+		}
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		i.State = models.StateCanceled
+		r.Transition(ctx, models.StateRunning, i)
+		i.State = models.StatePaused
+		start := time.Now()
+		r.Transition(ctx, models.StateRunning, i)
+		r.Wait()
+		if d := time.Since(start); d > 3*time.Second {
+			t.Errorf("Expected hook killed after timeout, took %s", d)
+		}
+
+		if len(e.errs) != 2 {
+			t.Fatalf("Expected 2 errors, got %v", e.errs)
+		}
+		msgs := e.errs[0].Error() + "\n" + e.errs[1].Error()
+		for _, exp := range []string{"hook cancel", "exit status 3", "hook pause", "timed out"} {
+			if !strings.Contains(msgs, exp) {
+				t.Errorf("Expected %q in errors:\n%s", exp, msgs)
+			}
+		}
+		if !strings.Contains(log.String(), "broken") {
+			t.Errorf("Expected output of failed hook in log:\n%s", log)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+// Package importer brings history from other time trackers into a
+// models.Repository.
+package importer
+
+import (
+	"context"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Outcome of an import
+type Result struct {
+	Imported []models.Interval
+	// Same category and start second as an existing interval
+	Duplicates []models.Interval
+	// Overlapping an existing or earlier imported interval
+	Overlaps []models.Interval
+}
+
+type Options struct {
+	// Only report what would be imported
+	DryRun bool
+	// Import intervals overlapping others instead of skipping them
+	AllowOverlap bool
+}
+
+func end(i models.Interval) time.Time {
+	return i.TimeStart.Add(i.TimeActual)
+}
+
+func overlaps(a, b models.Interval) bool {
+	return a.TimeStart.Before(end(b)) && b.TimeStart.Before(end(a))
+}
+
+func sameStart(a, b models.Interval) bool {
+	return a.Category == b.Category &&
+		a.TimeStart.Truncate(time.Second).Equal(b.TimeStart.Truncate(time.Second))
+}
+
+// Read intervals from r with src and create those which are neither
+// duplicates nor overlapping, in order of start time
+func Import(ctx context.Context, repo models.Repository, src Source, r io.Reader,
+	opts Options) (Result, error) {
+	res := Result{}
+
+	entries := []models.Interval{}
+	err := src(r, func(i models.Interval) error {
+		i.ID = 0
+		i.TimeStart = i.TimeStart.UTC()
+		entries = append(entries, i)
+		return nil
+	})
+	if err != nil || len(entries) == 0 {
+		return res, err
+	}
+	sort.SliceStable(entries, func(a, b int) bool {
+		return entries[a].TimeStart.Before(entries[b].TimeStart)
+	})
+
+	// Existing intervals which may clash, long ones can start a day early
+	first, last := entries[0].TimeStart, entries[0].TimeStart
+	for _, i := range entries {
+		if e := end(i); e.After(last) {
+			last = e
+		}
+	}
+	existing := []models.Interval{}
+	err = repo.Range(ctx, first.AddDate(0, 0, -1), last.Add(time.Second), func(i models.Interval) error {
+		existing = append(existing, i)
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+
+	for _, i := range entries {
+		dup, clash := false, false
+		for _, e := range existing {
+			if sameStart(i, e) {
+				dup = true
+				break
+			}
+			if overlaps(i, e) {
+				clash = true
+			}
+		}
+
+		switch {
+		case dup:
+			res.Duplicates = append(res.Duplicates, i)
+			continue
+		case clash && !opts.AllowOverlap:
+			res.Overlaps = append(res.Overlaps, i)
+			continue
+		case clash:
+			res.Overlaps = append(res.Overlaps, i)
+		}
+
+		if !opts.DryRun {
+			if i.ID, err = repo.Create(ctx, i); err != nil {
+				return res, err
+			}
+		}
+		res.Imported = append(res.Imported, i)
+		existing = append(existing, i)
+	}
+	return res, nil
+}
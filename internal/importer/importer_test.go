@@ -0,0 +1,211 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/repository"
+)
+
+func read(t *testing.T, src Source, in string) []models.Interval {
+	t.Helper()
+	data := []models.Interval{}
+	err := src(strings.NewReader(in), func(i models.Interval) error {
+		data = append(data, i)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCSVSource(t *testing.T) {
+	exported := `id,category,state,start,end,planned_seconds,actual_seconds,task,tags,project
+1,Pomodoro,Done,2023-09-04T10:00:00Z,2023-09-04T10:25:00Z,1500,1500,report,"work,docs",site
+2,ShortBreak,Canceled,2023-09-04T10:25:00Z,2023-09-04T10:26:00Z,300,60,,,
+`
+	src, err := NewSource(FormatCSV, CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := read(t, src, exported)
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 intervals, got %d", len(got))
+	}
+	if got[0].Task != "report" || strings.Join(got[0].Tags, "|") != "work|docs" ||
+		got[0].Project != "site" || got[0].TimeActual != 25*time.Minute {
+		t.Errorf("Unexpected interval %+v", got[0])
+	}
+	if got[1].Category != models.ShortBreakCategory || got[1].State != models.StateCanceled ||
+		got[1].TimePlanning != 5*time.Minute {
+		t.Errorf("Unexpected interval %+v", got[1])
+	}
+
+	mapping, err := ParseMapping("start=Begin, end=Stop,task=What")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := time.FixedZone("test", 2*60*60)
+	src, err = NewSource(FormatCSV, CSVOptions{
+		Mapping:    mapping,
+		TimeLayout: "02.01.2006 15:04",
+		Location:   loc,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = read(t, src, "Begin,Stop,What\n04.09.2023 10:00,04.09.2023 10:50,review\n")
+	exp := models.Interval{
+		Category:     models.PomodoCategory,
+		State:        models.StateDone,
+		TimeStart:    time.Date(2023, 9, 4, 8, 0, 0, 0, time.UTC),
+		TimePlanning: 50 * time.Minute,
+		TimeActual:   50 * time.Minute,
+		Task:         "review",
+	}
+	if len(got) != 1 || !got[0].TimeStart.Equal(exp.TimeStart) || got[0].TimeActual != exp.TimeActual ||
+		got[0].Task != exp.Task || got[0].Category != exp.Category || got[0].State != exp.State {
+		t.Errorf("Expected %+v, got %+v", exp, got)
+	}
+
+	if _, err := ParseMapping("begin=Start"); !errors.Is(err, ErrInvalidMapping) {
+		t.Errorf("Expected error %q, got %v", ErrInvalidMapping, err)
+	}
+}
+
+func TestInvalidDuration(t *testing.T) {
+	csvSrc, err := NewSource(FormatCSV, CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	togglSrc, err := NewSource(FormatToggl, CSVOptions{Location: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	timewSrc, err := NewSource(FormatTimewarrior, CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name string
+		src  Source
+		in   string
+		exp  string
+	}{
+		{"CSVEndBeforeStart", csvSrc, "start,end\n" +
+			"2023-09-04T10:00:00Z,2023-09-04T10:25:00Z\n" +
+			"2023-09-04T11:00:00Z,2023-09-04T10:00:00Z\n", "line 3"},
+		{"CSVZero", csvSrc, "start,actual_seconds\n2023-09-04T10:00:00Z,0\n", "line 2"},
+		{"Toggl", togglSrc, "Description,Start date,Start time,Duration\n" +
+			"Fix menu,2023-09-04,10:00:00,-01:00:00\n", "line 2"},
+		{"Timewarrior", timewSrc,
+			`[{"id":4,"start":"20230904T100000Z","end":"20230904T090000Z"}]`, "entry @4"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.src(strings.NewReader(tc.in), func(models.Interval) error { return nil })
+			if !errors.Is(err, ErrInvalidDuration) {
+				t.Fatalf("Expected error %q, got %v", ErrInvalidDuration, err)
+			}
+			if !strings.HasPrefix(err.Error(), tc.exp+":") {
+				t.Errorf("Expected error at %s, got %q", tc.exp, err)
+			}
+		})
+	}
+}
+
+func TestTimewarriorSource(t *testing.T) {
+	in := `[
+{"id":2,"start":"20230904T100000Z","end":"20230904T102500Z","tags":["work","docs"],"annotation":"report"},
+{"id":1,"start":"20230904T110000Z","tags":["running"]}
+]`
+	src, err := NewSource(FormatTimewarrior, CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := read(t, src, in)
+	if len(got) != 1 {
+		t.Fatalf("Expected open entry to be skipped, got %+v", got)
+	}
+	if got[0].TimeActual != 25*time.Minute || got[0].Task != "report" || len(got[0].Tags) != 2 {
+		t.Errorf("Unexpected interval %+v", got[0])
+	}
+}
+
+func TestTogglSource(t *testing.T) {
+	in := "\ufeffUser,Email,Client,Project,Task,Description,Billable,Start date,Start time,End date,End time,Duration,Tags,Amount ()\n" +
+		"me,me@example.com,,Website,,Fix menu,No,2023-09-04,10:00:00,2023-09-04,11:30:00,01:30:00,\"frontend, bug\",\n"
+	src, err := NewSource(FormatToggl, CSVOptions{Location: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := read(t, src, in)
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 interval, got %d", len(got))
+	}
+	if !got[0].TimeStart.Equal(time.Date(2023, 9, 4, 10, 0, 0, 0, time.UTC)) ||
+		got[0].TimeActual != 90*time.Minute || got[0].Task != "Fix menu" ||
+		strings.Join(got[0].Tags, "|") != "Website|frontend|bug" {
+		t.Errorf("Unexpected interval %+v", got[0])
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepo()
+	if _, err := repo.Create(ctx, models.Interval{
+		Category:   models.PomodoCategory,
+		State:      models.StateDone,
+		TimeStart:  time.Date(2023, 9, 4, 9, 0, 0, 0, time.UTC),
+		TimeActual: 25 * time.Minute,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	in := `start,actual_seconds,category
+2023-09-04T09:00:00Z,1500,Pomodoro
+2023-09-04T09:10:00Z,600,ShortBreak
+2023-09-04T10:00:00Z,1500,Pomodoro
+2023-09-04T10:20:00Z,600,Pomodoro
+2023-09-04T11:00:00Z,1500,Pomodoro
+`
+	src, err := NewSource(FormatCSV, CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Import(ctx, repo, src, strings.NewReader(in), Options{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Imported) != 2 || len(res.Duplicates) != 1 || len(res.Overlaps) != 2 {
+		t.Errorf("Expected 2 imported, 1 duplicate and 2 overlaps, got %+v", res)
+	}
+	if last, _ := repo.Last(ctx); last.ID != 1 {
+		t.Errorf("Expected dry run not to write, last ID is %d", last.ID)
+	}
+
+	res, err = Import(ctx, repo, src, strings.NewReader(in), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Imported) != 2 || res.Imported[1].ID != 3 {
+		t.Errorf("Expected 2 intervals created, got %+v", res.Imported)
+	}
+
+	// Imported entries are duplicates now, overlapping ones are allowed in
+	res, err = Import(ctx, repo, src, strings.NewReader(in), Options{AllowOverlap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Duplicates) != 3 || len(res.Imported) != 2 {
+		t.Errorf("Expected 3 duplicates and 2 overlapping imported, got %+v", res)
+	}
+}
@@ -0,0 +1,341 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+const (
+	FormatCSV         = "csv"
+	FormatTimewarrior = "timewarrior"
+	FormatToggl       = "toggl"
+)
+
+var (
+	ErrInvalidFormat  = fmt.Errorf("Invalid import format")
+	ErrInvalidMapping = fmt.Errorf("Invalid column mapping")
+	// Entries must have taken some time
+	ErrInvalidDuration = fmt.Errorf("Invalid duration")
+)
+
+// Fields of an interval that CSV columns can be mapped to
+const (
+	FieldStart    = "start"
+	FieldEnd      = "end"
+	FieldActual   = "actual"
+	FieldPlanned  = "planned"
+	FieldCategory = "category"
+	FieldState    = "state"
+	FieldTask     = "task"
+	FieldTags     = "tags"
+	FieldProject  = "project"
+)
+
+// Mapping from interval fields to CSV column names
+type Mapping map[string]string
+
+// Columns written by the export command
+func DefaultMapping() Mapping {
+	return Mapping{
+		FieldStart:    "start",
+		FieldEnd:      "end",
+		FieldActual:   "actual_seconds",
+		FieldPlanned:  "planned_seconds",
+		FieldCategory: "category",
+		FieldState:    "state",
+		FieldTask:     "task",
+		FieldTags:     "tags",
+		FieldProject:  "project",
+	}
+}
+
+// Parse mapping given as field=column pairs separated by commas,
+// fields not mentioned keep their default column
+func ParseMapping(s string) (Mapping, error) {
+	m := DefaultMapping()
+	if s == "" {
+		return m, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.TrimSpace(field)
+		if _, known := m[field]; !ok || !known {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMapping, pair)
+		}
+		m[field] = strings.TrimSpace(column)
+	}
+	return m, nil
+}
+
+// Source reads entries from r and calls fn for each of them
+type Source func(r io.Reader, fn func(models.Interval) error) error
+
+// Options of the CSV source
+type CSVOptions struct {
+	Mapping Mapping
+	// Layout of start and end, RFC 3339 when empty
+	TimeLayout string
+	// Location of times without offset
+	Location *time.Location
+}
+
+// Return source of format
+func NewSource(format string, opts CSVOptions) (Source, error) {
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+	if opts.TimeLayout == "" {
+		opts.TimeLayout = time.RFC3339
+	}
+	if opts.Mapping == nil {
+		opts.Mapping = DefaultMapping()
+	}
+
+	switch format {
+	case FormatCSV:
+		return func(r io.Reader, fn func(models.Interval) error) error {
+			return readCSV(r, opts, fn)
+		}, nil
+	case FormatTimewarrior:
+		return readTimewarrior, nil
+	case FormatToggl:
+		return func(r io.Reader, fn func(models.Interval) error) error {
+			return readToggl(r, opts.Location, fn)
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrInvalidFormat, format)
+}
+
+// Parse duration given as seconds, hh:mm:ss or Go duration
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(n * float64(time.Second)), nil
+	}
+
+	if parts := strings.Split(s, ":"); len(parts) == 3 {
+		var d time.Duration
+		for n, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+			v, err := strconv.Atoi(parts[n])
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			d += time.Duration(v) * unit
+		}
+		return d, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func splitTags(s string) []string {
+	tags := []string{}
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+// csvRecords calls fn with every row of a CSV with header as a map
+// from column name to value
+func csvRecords(r io.Reader, fn func(line int, row map[string]string) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return err
+	}
+	for n := range header {
+		// Spreadsheets like to start files with a byte order mark
+		header[n] = strings.TrimSpace(strings.TrimPrefix(header[n], "\ufeff"))
+	}
+
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		row := make(map[string]string, len(header))
+		for n, v := range rec {
+			if n < len(header) {
+				row[header[n]] = v
+			}
+		}
+		line, _ := cr.FieldPos(0)
+		if err := fn(line, row); err != nil {
+			return err
+		}
+	}
+}
+
+func readCSV(r io.Reader, opts CSVOptions, fn func(models.Interval) error) error {
+	m := opts.Mapping
+	parseTime := func(s string) (time.Time, error) {
+		return time.ParseInLocation(opts.TimeLayout, strings.TrimSpace(s), opts.Location)
+	}
+
+	return csvRecords(r, func(line int, row map[string]string) error {
+		i := models.Interval{
+			Category: models.PomodoCategory,
+			State:    models.StateDone,
+		}
+		fail := func(field string, err error) error {
+			return fmt.Errorf("line %d: %s: %w", line, m[field], err)
+		}
+
+		v, ok := row[m[FieldStart]]
+		if !ok {
+			return fmt.Errorf("%w: no column %q for %s", ErrInvalidMapping, m[FieldStart], FieldStart)
+		}
+		var err error
+		if i.TimeStart, err = parseTime(v); err != nil {
+			return fail(FieldStart, err)
+		}
+
+		if v := row[m[FieldActual]]; v != "" {
+			if i.TimeActual, err = parseDuration(v); err != nil {
+				return fail(FieldActual, err)
+			}
+		} else if v := row[m[FieldEnd]]; v != "" {
+			end, err := parseTime(v)
+			if err != nil {
+				return fail(FieldEnd, err)
+			}
+			i.TimeActual = end.Sub(i.TimeStart)
+		} else {
+			return fmt.Errorf("line %d: no %s or %s", line, m[FieldEnd], m[FieldActual])
+		}
+		if i.TimeActual <= 0 {
+			return fmt.Errorf("line %d: %w: %s", line, ErrInvalidDuration, i.TimeActual)
+		}
+
+		i.TimePlanning = i.TimeActual
+		if v := row[m[FieldPlanned]]; v != "" {
+			if i.TimePlanning, err = parseDuration(v); err != nil {
+				return fail(FieldPlanned, err)
+			}
+		}
+		if v := row[m[FieldCategory]]; v != "" {
+			i.Category = v
+		}
+		if v := row[m[FieldState]]; v != "" {
+			if i.State, err = models.ParseState(v); err != nil {
+				return fail(FieldState, err)
+			}
+		}
+		i.Task = row[m[FieldTask]]
+		i.Tags = splitTags(row[m[FieldTags]])
+		i.Project = strings.TrimSpace(row[m[FieldProject]])
+
+		return fn(i)
+	})
+}
+
+// Entry of `timew export`
+type timewEntry struct {
+	ID         int      `json:"id"`
+	Start      string   `json:"start"`
+	End        string   `json:"end"`
+	Tags       []string `json:"tags"`
+	Annotation string   `json:"annotation"`
+}
+
+const timewTime = "20060102T150405Z"
+
+func readTimewarrior(r io.Reader, fn func(models.Interval) error) error {
+	dec := json.NewDecoder(r)
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		e := timewEntry{}
+		if err := dec.Decode(&e); err != nil {
+			return err
+		}
+		// Still tracking
+		if e.End == "" {
+			continue
+		}
+
+		start, err := time.Parse(timewTime, e.Start)
+		if err != nil {
+			return fmt.Errorf("entry @%d: %w", e.ID, err)
+		}
+		end, err := time.Parse(timewTime, e.End)
+		if err != nil {
+			return fmt.Errorf("entry @%d: %w", e.ID, err)
+		}
+		if !end.After(start) {
+			return fmt.Errorf("entry @%d: %w: %s", e.ID, ErrInvalidDuration, end.Sub(start))
+		}
+
+		err = fn(models.Interval{
+			Category:     models.PomodoCategory,
+			State:        models.StateDone,
+			TimeStart:    start,
+			TimePlanning: end.Sub(start),
+			TimeActual:   end.Sub(start),
+			Task:         e.Annotation,
+			Tags:         e.Tags,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := dec.Token()
+	return err
+}
+
+// Read detailed report exported by Toggl Track, projects become tags
+func readToggl(r io.Reader, loc *time.Location, fn func(models.Interval) error) error {
+	return csvRecords(r, func(line int, row map[string]string) error {
+		start, err := time.ParseInLocation("2006-01-02 15:04:05",
+			row["Start date"]+" "+row["Start time"], loc)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		d, err := parseDuration(row["Duration"])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("line %d: %w: %s", line, ErrInvalidDuration, d)
+		}
+
+		var tags []string
+		if p := strings.TrimSpace(row["Project"]); p != "" {
+			tags = append(tags, p)
+		}
+		tags = append(tags, splitTags(row["Tags"])...)
+
+		return fn(models.Interval{
+			Category:     models.PomodoCategory,
+			State:        models.StateDone,
+			TimeStart:    start,
+			TimePlanning: d,
+			TimeActual:   d,
+			Task:         row["Description"],
+			Tags:         tags,
+		})
+	})
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/xor111xor/pomodoro-go/internal/importer"
 	"github.com/xor111xor/pomodoro-go/internal/models"
 )
 
@@ -75,11 +79,13 @@ func TestNewConfig(t *testing.T) {
 }
 
 func TestGetInterval(t *testing.T) {
+	ctx := context.Background()
 	repo, cleanup := getRepo(t)
 	defer cleanup()
 
 	const duration = 1 * time.Millisecond
 	config, _ := models.NewConfig(repo, 3*duration, 2*duration, duration)
+	config.Project = "pomodoro-go"
 
 	for i := 1; i <= 16; i++ {
 		var (
@@ -102,7 +108,7 @@ func TestGetInterval(t *testing.T) {
 		tesName := fmt.Sprintf("%s %d", expCategory, i)
 
 		t.Run(tesName, func(t *testing.T) {
-			res, err := models.GetInterval(config)
+			res, err := models.GetInterval(ctx, config)
 			if err != nil {
 				t.Errorf("Expected no error, got %q.\n", err)
 			}
@@ -122,18 +128,71 @@ func TestGetInterval(t *testing.T) {
 				t.Errorf("Expected State %q, got %q.\n", models.StateNotStarted, res.State)
 			}
 
-			ui, err := repo.ByID(res.ID)
+			ui, err := repo.ByID(ctx, res.ID)
 			if err != nil {
 				t.Errorf("Expected no error, got %q.\n", err)
 			}
 			if ui.State != models.StateDone {
 				t.Errorf("Expected state %q, got %q.\n", models.StateDone, ui.State)
 			}
+			if ui.Project != config.Project {
+				t.Errorf("Expected project %q, got %q.\n", config.Project, ui.Project)
+			}
 		})
 
 	}
 }
 
+// The task picked in the dashboard, concurrently with starting
+// intervals, is recorded over the configured one
+func TestPickedTask(t *testing.T) {
+	ctx := context.Background()
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config, err := models.NewConfig(repo, time.Minute, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Task = "configured"
+
+	i, err := models.NewInterval(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Task != "configured" {
+		t.Errorf("Expected configured task, got %q", i.Task)
+	}
+	// The next interval is a pomodoro too
+	if err := i.Stop(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		config.Picked.Pick(models.Task{ID: "1", Description: "picked"})
+	}()
+	wg.Wait()
+
+	i, err = models.NewInterval(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Task != "picked" {
+		t.Errorf("Expected picked task, got %q", i.Task)
+	}
+	if err := i.Stop(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	config.Picked.Pick(models.Task{})
+	if i, err = models.NewInterval(ctx, config); err != nil || i.Task != "" {
+		t.Errorf("Expected no task once none is picked, got %q, %v", i.Task, err)
+	}
+}
+
 func TestPause(t *testing.T) {
 	const duration = 2 * time.Second
 
@@ -170,7 +229,7 @@ func TestPause(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			ctx, cancel := context.WithCancel(context.Background())
 
-			i, err := models.GetInterval(config)
+			i, err := models.GetInterval(ctx, config)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -180,7 +239,7 @@ func TestPause(t *testing.T) {
 				t.Errorf("End callback should not be executed")
 			}
 			periodic := func(i models.Interval) {
-				if err := i.Pause(config); err != nil {
+				if err := i.Pause(ctx, config); err != nil {
 					t.Fatal(err)
 				}
 			}
@@ -191,12 +250,12 @@ func TestPause(t *testing.T) {
 				}
 			}
 
-			i, err = models.GetInterval(config)
+			i, err = models.GetInterval(ctx, config)
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			err = i.Pause(config)
+			err = i.Pause(ctx, config)
 			if err != nil {
 				if !errors.Is(err, expError) {
 					t.Fatalf("Expected error %q got %q", expError, err)
@@ -206,7 +265,7 @@ func TestPause(t *testing.T) {
 				t.Fatalf("Expected error %q, got nil", expError)
 			}
 
-			i, err = repo.ByID(i.ID)
+			i, err = repo.ByID(context.Background(), i.ID)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -259,7 +318,7 @@ func TestStart(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			ctx, cancel := context.WithCancel(context.Background())
 
-			i, err := models.GetInterval(config)
+			i, err := models.GetInterval(ctx, config)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -297,7 +356,7 @@ func TestStart(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			i, err = repo.ByID(i.ID)
+			i, err = repo.ByID(context.Background(), i.ID)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -313,3 +372,234 @@ func TestStart(t *testing.T) {
 		})
 	}
 }
+
+func TestStopSkip(t *testing.T) {
+	ctx := context.Background()
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config, err := models.NewConfig(repo, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name        string
+		action      func(models.Interval) error
+		expState    int
+		expCategory string
+	}{
+		{"Skip", func(i models.Interval) error { return i.Skip(ctx, config) },
+			models.StateSkipped, models.ShortBreakCategory},
+		{"Stop", func(i models.Interval) error { return i.Stop(ctx, config) },
+			models.StateCanceled, models.PomodoCategory},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			i, err := models.GetInterval(ctx, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tc.action(i); err != nil {
+				t.Fatal(err)
+			}
+			if err := tc.action(i); !errors.Is(err, models.ErrIntervalCompleted) {
+				t.Errorf("Expected error %q, got %v", models.ErrIntervalCompleted, err)
+			}
+
+			i, err = repo.ByID(ctx, i.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i.State != tc.expState {
+				t.Errorf("Expected state %d, got %d", tc.expState, i.State)
+			}
+			// Only pomodoros which ran out are counted
+			if n, err := models.DailyPomodoros(ctx, time.Now(), config); err != nil || n != 0 {
+				t.Errorf("Expected no pomodoros done, got %d, %v", n, err)
+			}
+
+			next, err := models.NextCategory(ctx, repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next != tc.expCategory {
+				t.Errorf("Expected next category %q, got %q", tc.expCategory, next)
+			}
+
+			// Move on to the next pomodoro
+			if next != models.PomodoCategory {
+				i, err := models.GetInterval(ctx, config)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := i.Skip(ctx, config); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+type transition struct {
+	from, to int
+}
+
+type recorder struct {
+	mu          sync.Mutex
+	transitions []transition
+}
+
+func (r *recorder) Transition(ctx context.Context, from int, i models.Interval) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transitions = append(r.transitions, transition{from, i.State})
+}
+
+func TestObservers(t *testing.T) {
+	ctx := context.Background()
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config, err := models.NewConfig(repo, time.Second, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &recorder{}
+	config.Observers = []models.Observer{r}
+
+	noop := func(models.Interval) {}
+	i, err := models.GetInterval(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- i.Start(runCtx, config, noop, noop, noop) }()
+	time.Sleep(100 * time.Millisecond)
+
+	i, err = repo.ByID(ctx, i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Pause(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	i, err = repo.ByID(ctx, i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Finished
+	if err := i.Start(ctx, config, noop, noop, noop); err != nil {
+		t.Fatal(err)
+	}
+
+	// Canceled by quitting
+	i, err = models.GetInterval(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { done <- i.Start(runCtx, config, noop, noop, noop) }()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	i, err = models.GetInterval(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Skip(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	i, err = models.GetInterval(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Stop(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []transition{
+		{models.StateNotStarted, models.StateRunning},
+		{models.StateRunning, models.StatePaused},
+		{models.StatePaused, models.StateRunning},
+		{models.StateRunning, models.StateDone},
+		{models.StateNotStarted, models.StateRunning},
+		{models.StateRunning, models.StateCanceled},
+		{models.StateNotStarted, models.StateSkipped},
+		{models.StateNotStarted, models.StateCanceled},
+	}
+	if !reflect.DeepEqual(r.transitions, exp) {
+		t.Errorf("Expected transitions %v, got %v", exp, r.transitions)
+	}
+}
+
+// Imported history gets higher IDs than the paused pomodoro, which must
+// still be the one resumed
+func TestImportWhilePaused(t *testing.T) {
+	const duration = 2 * time.Second
+	ctx := context.Background()
+
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config, err := models.NewConfig(repo, duration, duration, duration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i, err := models.GetInterval(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noop := func(models.Interval) {}
+	pause := func(i models.Interval) {
+		if err := i.Pause(ctx, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := i.Start(ctx, config, noop, pause, noop); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := importer.NewSource(importer.FormatCSV, importer.CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := "start,actual_seconds,category\n2023-09-04T09:00:00Z,1500,Pomodoro\n"
+	res, err := importer.Import(ctx, repo, src, strings.NewReader(in), importer.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Imported) != 1 || res.Imported[0].ID <= i.ID {
+		t.Fatalf("Expected 1 interval imported after the paused one, got %+v", res.Imported)
+	}
+
+	paused, err := models.GetInterval(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paused.ID != i.ID || paused.State != models.StatePaused {
+		t.Fatalf("Expected paused interval %d, got %d in state %d", i.ID, paused.ID, paused.State)
+	}
+	if err := paused.Start(ctx, config, noop, noop, noop); err != nil {
+		t.Fatal(err)
+	}
+	if done, err := repo.ByID(ctx, i.ID); err != nil || done.State != models.StateDone {
+		t.Errorf("Expected interval %d done, got %+v, %v", i.ID, done, err)
+	}
+
+	next, err := models.GetInterval(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Category != models.ShortBreakCategory {
+		t.Errorf("Expected %s after the pomodoro, got %s", models.ShortBreakCategory, next.Category)
+	}
+}
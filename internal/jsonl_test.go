@@ -0,0 +1,102 @@
+//go:build jsonl
+
+package internal_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/repository"
+)
+
+func getRepo(t *testing.T) (models.Repository, func()) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pomo.jsonl")
+	repo, err := repository.NewJSONLRepo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return repo, func() {
+		repo.Close()
+	}
+}
+
+func TestJSONLReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "pomo.jsonl")
+
+	first, err := repository.NewJSONLRepo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	second, err := repository.NewJSONLRepo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	id, err := first.Create(ctx, models.Interval{
+		Category:     models.PomodoCategory,
+		TimeStart:    time.Now(),
+		TimePlanning: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i, err := second.ByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.State = models.StateDone
+	i.TimeActual = time.Minute
+	if err := second.Update(ctx, i); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Torn write from a crashed process
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"upd`)
+	f.Close()
+
+	third, err := repository.NewJSONLRepo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.Close()
+
+	for _, r := range []models.Repository{second, third} {
+		last, err := r.Last(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last.ID != id || last.State != models.StateDone || last.TimeActual != time.Minute {
+			t.Errorf("Expected done interval %d, got %+v", id, last)
+		}
+	}
+
+	if _, err := second.Create(ctx, models.Interval{Category: models.ShortBreakCategory}); err != nil {
+		t.Fatal(err)
+	}
+	breaks, err := first.Breaks(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(breaks) != 1 {
+		t.Errorf("Expected 1 break, got %d", len(breaks))
+	}
+}
@@ -0,0 +1,199 @@
+// Package metrics exposes the timer in the Prometheus text format.
+//
+// Gauges describe the current interval, counters and histograms are
+// derived from the intervals finished, stopped or skipped while the
+// collector runs:
+//
+//	pomodoro_state{state}                           1 for the state of the last interval
+//	pomodoro_remaining_seconds                      time left in the last interval
+//	pomodoro_planned_seconds                        planned duration of the last interval
+//	pomodoro_today_pomodoros                        pomodoros completed today
+//	pomodoro_intervals_total{category,state}        intervals done, skipped or canceled
+//	pomodoro_interval_actual_seconds{category}      histogram of the time spent in intervals
+//	pomodoro_interval_planned_seconds{category}     histogram of the planned durations
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Upper bounds of the duration histograms in seconds
+var buckets = []float64{60, 300, 600, 900, 1200, 1500, 1800, 2700, 3600, 5400}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(buckets))
+	}
+	for i, b := range buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+type intervalKey struct {
+	category string
+	state    string
+}
+
+// Collector follows the timer and serves its metrics over HTTP
+type Collector struct {
+	config *models.IntervalConfig
+	timer  daemon.Timer
+
+	mu        sync.Mutex
+	intervals map[intervalKey]uint64
+	actual    map[string]*histogram
+	planned   map[string]*histogram
+}
+
+func New(config *models.IntervalConfig, timer daemon.Timer) *Collector {
+	return &Collector{
+		config:    config,
+		timer:     timer,
+		intervals: map[intervalKey]uint64{},
+		actual:    map[string]*histogram{},
+		planned:   map[string]*histogram{},
+	}
+}
+
+// Run counts finished intervals until ctx is done
+func (c *Collector) Run(ctx context.Context) error {
+	return c.timer.Subscribe(ctx, func(ev daemon.Event) {
+		switch ev.Name {
+		case daemon.EventDone, daemon.EventStop, daemon.EventSkip:
+		default:
+			return
+		}
+
+		i, err := c.config.Repo.ByID(ctx, ev.Status.ID)
+		if err != nil {
+			log.Printf("metrics: %s event: %v", ev.Name, err)
+			return
+		}
+		c.Observe(i)
+	})
+}
+
+// Observe counts finished interval i
+func (c *Collector) Observe(i models.Interval) {
+	if !models.Ended(i.State) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.intervals[intervalKey{i.Category, strings.ToLower(models.StateName(i.State))}]++
+	if c.actual[i.Category] == nil {
+		c.actual[i.Category] = &histogram{}
+		c.planned[i.Category] = &histogram{}
+	}
+	c.actual[i.Category].observe(i.TimeActual.Seconds())
+	c.planned[i.Category].observe(i.TimePlanning.Seconds())
+}
+
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s, err := c.timer.Do(r.Context(), daemon.CmdStatus)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	c.write(bw, s)
+	bw.Flush()
+}
+
+// Write metrics in the text exposition format
+func (c *Collector) write(w *bufio.Writer, s models.Status) {
+	header(w, "pomodoro_state", "gauge", "1 for the state of the last interval.")
+	for state := models.StateNotStarted; state <= models.StateSkipped; state++ {
+		v := 0
+		if s.ID != 0 && s.State == state {
+			v = 1
+		}
+		fmt.Fprintf(w, "pomodoro_state{state=%s} %d\n",
+			quote(strings.ToLower(models.StateName(state))), v)
+	}
+	header(w, "pomodoro_remaining_seconds", "gauge", "Time left in the last interval.")
+	fmt.Fprintf(w, "pomodoro_remaining_seconds %s\n", number(s.Remaining.Seconds()))
+	header(w, "pomodoro_planned_seconds", "gauge", "Planned duration of the last interval.")
+	fmt.Fprintf(w, "pomodoro_planned_seconds %s\n", number(s.Planned.Seconds()))
+	header(w, "pomodoro_today_pomodoros", "gauge", "Pomodoros completed today.")
+	fmt.Fprintf(w, "pomodoro_today_pomodoros %d\n", s.Today)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	header(w, "pomodoro_intervals_total", "counter", "Intervals done, skipped or canceled.")
+	keys := make([]intervalKey, 0, len(c.intervals))
+	for k := range c.intervals {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].category != keys[j].category {
+			return keys[i].category < keys[j].category
+		}
+		return keys[i].state < keys[j].state
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "pomodoro_intervals_total{category=%s,state=%s} %d\n",
+			quote(k.category), quote(k.state), c.intervals[k])
+	}
+
+	writeHistograms(w, "pomodoro_interval_actual_seconds", "Time spent in intervals.", c.actual)
+	writeHistograms(w, "pomodoro_interval_planned_seconds", "Planned durations of intervals.", c.planned)
+}
+
+func writeHistograms(w *bufio.Writer, name, help string, hs map[string]*histogram) {
+	header(w, name, "histogram", help)
+	categories := make([]string, 0, len(hs))
+	for category := range hs {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	for _, category := range categories {
+		h := hs[category]
+		for i, b := range buckets {
+			fmt.Fprintf(w, "%s_bucket{category=%s,le=%s} %d\n",
+				name, quote(category), quote(number(b)), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{category=%s,le=\"+Inf\"} %d\n", name, quote(category), h.count)
+		fmt.Fprintf(w, "%s_sum{category=%s} %s\n", name, quote(category), number(h.sum))
+		fmt.Fprintf(w, "%s_count{category=%s} %d\n", name, quote(category), h.count)
+	}
+}
+
+func header(w *bufio.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func number(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Quote label value, escaping backslash, quote and newline
+func quote(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
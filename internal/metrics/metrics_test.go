@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/repository"
+)
+
+func scrape(t *testing.T, c *Collector) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	return w.Body.String()
+}
+
+func TestCollector(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config, err := models.NewConfig(repository.NewInMemoryRepo(), time.Second, 0, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timer := daemon.NewServer(config)
+	defer timer.Close()
+
+	c := New(config, timer)
+	events := make(chan daemon.Event, 64)
+	go c.Run(ctx)
+	go timer.Subscribe(ctx, func(ev daemon.Event) { events <- ev })
+
+	if _, err := timer.Do(ctx, daemon.CmdStart); err != nil {
+		t.Fatal(err)
+	}
+	out := scrape(t, c)
+	for _, line := range []string{
+		`pomodoro_state{state="running"} 1`,
+		`pomodoro_state{state="paused"} 0`,
+		`pomodoro_planned_seconds 1`,
+		`pomodoro_today_pomodoros 0`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected %q in metrics:\n%s", line, out)
+		}
+	}
+
+	// Pomodoro done, then the break stopped
+	timeout := time.After(5 * time.Second)
+	for ev := (daemon.Event{}); ev.Name != daemon.EventDone; {
+		select {
+		case ev = <-events:
+		case <-timeout:
+			t.Fatal("Timeout waiting for done event")
+		}
+	}
+	if _, err := timer.Do(ctx, daemon.CmdStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := timer.Do(ctx, daemon.CmdStop); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`pomodoro_state{state="canceled"} 1`,
+		`pomodoro_today_pomodoros 1`,
+		`pomodoro_intervals_total{category="Pomodoro",state="done"} 1`,
+		`pomodoro_intervals_total{category="ShortBreak",state="canceled"} 1`,
+		`pomodoro_interval_actual_seconds_bucket{category="Pomodoro",le="60"} 1`,
+		`pomodoro_interval_actual_seconds_count{category="Pomodoro"} 1`,
+		`pomodoro_interval_planned_seconds_sum{category="ShortBreak"} 2`,
+		`pomodoro_interval_planned_seconds_bucket{category="ShortBreak",le="+Inf"} 1`,
+	}
+	// The collector counts the events on its own subscription
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		out = scrape(t, c)
+		missing := ""
+		for _, line := range want {
+			if !strings.Contains(out, line+"\n") {
+				missing = line
+				break
+			}
+		}
+		if missing == "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %q in metrics:\n%s", missing, out)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if got, want := quote("a\"b\\c\nd"), `"a\"b\\c\nd"`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrEventOverlap   = fmt.Errorf("Pomodoro overlaps event")
+	ErrInvalidWorkday = fmt.Errorf("Invalid workday")
+)
+
+// Look this far ahead for the next event
+const eventHorizon = 24 * time.Hour
+
+// Event of a calendar, like a meeting, taking [Start, End)
+type Event struct {
+	Summary string
+	Start   time.Time
+	End     time.Time
+}
+
+// Calendar lists the events overlapping [start, end) in order of start
+// time
+type Calendar interface {
+	Events(start, end time.Time) ([]Event, error)
+}
+
+func (c *IntervalConfig) warn(err error) {
+	if c.Warn != nil {
+		c.Warn(err)
+	}
+}
+
+// Return the next event starting after now within a day, false without
+// a calendar or events
+func NextEvent(config *IntervalConfig, now time.Time) (Event, bool, error) {
+	if config.Calendar == nil {
+		return Event{}, false, nil
+	}
+	events, err := config.Calendar.Events(now, now.Add(eventHorizon))
+	if err != nil {
+		return Event{}, false, err
+	}
+	for _, e := range events {
+		if e.Start.After(now) {
+			return e, true, nil
+		}
+	}
+	return Event{}, false, nil
+}
+
+// Shorten pomodoro i starting at now to end before the next event, or
+// only warn about the overlap
+func (c *IntervalConfig) planAround(i *Interval, now time.Time) {
+	e, ok, err := NextEvent(c, now)
+	if err != nil {
+		c.warn(fmt.Errorf("calendar: %w", err))
+		return
+	}
+	if !ok || !e.Start.Before(now.Add(i.TimePlanning)) {
+		return
+	}
+
+	at := e.Start.In(c.location()).Format("15:04")
+	left := e.Start.Sub(now).Truncate(time.Minute)
+	if !c.ShortenForEvents || left < time.Minute {
+		c.warn(fmt.Errorf("%w: %q at %s", ErrEventOverlap, e.Summary, at))
+		return
+	}
+	i.TimePlanning = left
+	c.warn(fmt.Errorf("%w: %q at %s, shortened to %s", ErrEventOverlap, e.Summary, at, left))
+}
+
+func (c *IntervalConfig) location() *time.Location {
+	if c.Location == nil {
+		return time.Local
+	}
+	return c.Location
+}
+
+// Return the time in [start, end) taken by events, counting overlapping
+// events once
+func EventTime(config *IntervalConfig, start, end time.Time) (time.Duration, error) {
+	if config.Calendar == nil {
+		return 0, nil
+	}
+	events, err := config.Calendar.Events(start, end)
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(events, func(a, b int) bool { return events[a].Start.Before(events[b].Start) })
+
+	var total time.Duration
+	covered := start
+	for _, e := range events {
+		s, t := e.Start, e.End
+		if s.Before(covered) {
+			s = covered
+		}
+		if t.After(end) {
+			t = end
+		}
+		if t.After(s) {
+			total += t.Sub(s)
+			covered = t
+		}
+	}
+	return total, nil
+}
+
+// Parse working hours like "09:00-17:00" as offsets from midnight
+func ParseWorkday(s string) (time.Duration, time.Duration, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidWorkday, s)
+	}
+	start, err := ParseDayStart(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidWorkday, s)
+	}
+	end, err := ParseDayStart(strings.TrimSpace(to))
+	if err != nil || end <= start {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidWorkday, s)
+	}
+	return start, end, nil
+}
+
+// Return the working hours of the configured day containing day left
+// after events, zero without working hours
+func DailyCapacity(day time.Time, config *IntervalConfig) (time.Duration, error) {
+	if config.WorkdayEnd <= config.WorkdayStart {
+		return 0, nil
+	}
+	y, m, d := config.Day(day).Date()
+	loc := config.location()
+	start := time.Date(y, m, d, 0, 0, 0, int(config.WorkdayStart), loc)
+	end := time.Date(y, m, d, 0, 0, 0, int(config.WorkdayEnd), loc)
+
+	busy, err := EventTime(config, start, end)
+	if err != nil {
+		return 0, err
+	}
+	return end.Sub(start) - busy, nil
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+var ErrInvalidDayStart = fmt.Errorf("Invalid day start")
+
+// Parse start of the day as "15:04" or duration after midnight
+func ParseDayStart(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		t, terr := time.Parse("15:04", s)
+		if terr != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidDayStart, s)
+		}
+		d = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+
+	if d < 0 || d >= 24*time.Hour {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidDayStart, s)
+	}
+	return d, nil
+}
+
+// Return the configured day containing t as [start, end)
+func (c *IntervalConfig) DayBounds(t time.Time) (time.Time, time.Time) {
+	return c.DateBounds(c.Day(t))
+}
+
+// Return the configured day of the calendar date of day as [start, end)
+func (c *IntervalConfig) DateBounds(day time.Time) (time.Time, time.Time) {
+	y, m, d := day.Date()
+
+	// Day start is wall clock time, so it survives daylight saving changes
+	start := time.Date(y, m, d, 0, 0, 0, int(c.DayStart), day.Location())
+	end := time.Date(y, m, d+1, 0, 0, 0, int(c.DayStart), day.Location())
+	return start, end
+}
+
+// Return midnight of the calendar date of the configured day containing t
+func (c *IntervalConfig) Day(t time.Time) time.Time {
+	loc := c.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	t = t.In(loc)
+	y, m, d := t.Date()
+	if t.Before(time.Date(y, m, d, 0, 0, 0, int(c.DayStart), loc)) {
+		d--
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,35 @@
+package models
+
+// GitRepo is the working tree pomodoros are spent in
+type GitRepo interface {
+	// Top directory of the working tree
+	Dir() string
+	// Current commit, empty before the first one
+	Head() (string, error)
+}
+
+// Return the function recording the HEAD of the configured working
+// tree on an interval starting, or ending with end set. Nothing is
+// recorded without a working tree or when reading HEAD fails.
+func (c *IntervalConfig) recordHead(end bool) func(*Interval) {
+	if c.Git == nil {
+		return func(*Interval) {}
+	}
+	head, err := c.Git.Head()
+	if err != nil {
+		c.warn(err)
+		return func(*Interval) {}
+	}
+	dir := c.Git.Dir()
+
+	return func(i *Interval) {
+		if !end {
+			i.GitDir, i.HeadStart = dir, head
+			return
+		}
+		// Ended from another working tree than it started in
+		if i.GitDir == dir {
+			i.HeadEnd = head
+		}
+	}
+}
@@ -2,7 +2,9 @@ package models
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -18,14 +20,50 @@ const (
 	StatePaused
 	StateCanceled
 	StateDone
+	// Completed early, not counted as done
+	StateSkipped
 )
 
+var stateNames = map[int]string{
+	StateNotStarted: "NotStarted",
+	StateRunning:    "Running",
+	StatePaused:     "Paused",
+	StateCanceled:   "Canceled",
+	StateDone:       "Done",
+	StateSkipped:    "Skipped",
+}
+
+// Is an interval in state over: done, skipped or canceled
+func Ended(state int) bool {
+	return state == StateDone || state == StateSkipped || state == StateCanceled
+}
+
+// Return readable name of the interval state
+func StateName(state int) string {
+	if name, ok := stateNames[state]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", state)
+}
+
+// Return the interval state with readable name
+func ParseState(name string) (int, error) {
+	for state, n := range stateNames {
+		if strings.EqualFold(n, name) {
+			return state, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrInvalidState, name)
+}
+
 var (
 	ErrNoIntervals        = fmt.Errorf("No interval")
 	ErrIntervalNotRunning = fmt.Errorf("Interval not running")
+	ErrIntervalNotPaused  = fmt.Errorf("Interval not paused")
 	ErrIntervalCompleted  = fmt.Errorf("Interval completed")
 	ErrInvalidState       = fmt.Errorf("Intervarl invalid state")
 	ErrInvalidID          = fmt.Errorf("Interval invalid id")
+	ErrInvalidBackup      = fmt.Errorf("Invalid backup")
 )
 
 type Interval struct {
@@ -35,15 +73,39 @@ type Interval struct {
 	TimeStart    time.Time
 	TimePlanning time.Duration
 	TimeActual   time.Duration
+	// Task and Tags describe what a pomodoro was spent on
+	Task string
+	Tags []string
+	// Project the interval was spent on, recorded on breaks as well
+	Project string
+	// Git working tree of the interval with its HEAD commit when the
+	// interval started and ended, empty when not recorded
+	GitDir    string
+	HeadStart string
+	HeadEnd   string
 }
 
 type Repository interface {
-	Create(i Interval) (int64, error)
-	Update(i Interval) error
-	Last() (Interval, error)
-	ByID(int64) (Interval, error)
-	Breaks(n int) ([]Interval, error)
-	CategorySummary(day time.Time, filter string) (time.Duration, error)
+	Create(ctx context.Context, i Interval) (int64, error)
+	// Update and Modify keep the planned duration and category given on
+	// Create
+	Update(ctx context.Context, i Interval) error
+	Modify(ctx context.Context, id int64, fn func(*Interval) error) error
+	Last(ctx context.Context) (Interval, error)
+	ByID(ctx context.Context, id int64) (Interval, error)
+	Breaks(ctx context.Context, n int) ([]Interval, error)
+	CategorySummary(ctx context.Context, start, end time.Time, filter string) (time.Duration, error)
+	// Range calls fn for intervals started in [start, end) in order of
+	// start time, stopping at the first error. fn must not call the
+	// repository.
+	Range(ctx context.Context, start, end time.Time, fn func(Interval) error) error
+}
+
+// Backuper is implemented by repositories able to take consistent
+// snapshots of themselves while in use
+type Backuper interface {
+	Backup(ctx context.Context, dest string) error
+	Restore(ctx context.Context, src string) error
 }
 
 type IntervalConfig struct {
@@ -51,6 +113,47 @@ type IntervalConfig struct {
 	PomoDuration       time.Duration
 	LongBreakDuration  time.Duration
 	ShortBreakDuration time.Duration
+	// Days for summaries are counted in Location starting DayStart
+	// after midnight
+	Location *time.Location
+	DayStart time.Duration
+	// Recorded on new pomodoros
+	Task string
+	Tags []string
+	// Recorded on new intervals
+	Project string
+	// Told about the state changes made through this config
+	Observers []Observer
+	// Meetings new pomodoros are planned around, nil without any. With
+	// ShortenForEvents pomodoros end before the next meeting, otherwise
+	// the overlap is only reported to Warn.
+	Calendar         Calendar
+	ShortenForEvents bool
+	// Working hours as offsets from midnight for DailyCapacity, none
+	// when equal
+	WorkdayStart time.Duration
+	WorkdayEnd   time.Duration
+	// Git working tree whose HEAD is recorded on intervals, nil without
+	// any
+	Git GitRepo
+	// Tasks offered by the dashboard for new pomodoros, nil without any.
+	// The one picked is recorded instead of Task.
+	Tasks  TaskSource
+	Picked *PickedTask
+	// Told about problems which do not stop the timer, may be nil
+	Warn func(error)
+}
+
+// Observer is called after an interval changed from state from to
+// i.State. It must not block, failures are the observer's to report.
+type Observer interface {
+	Transition(ctx context.Context, from int, i Interval)
+}
+
+func (c *IntervalConfig) notify(ctx context.Context, from int, i Interval) {
+	for _, o := range c.Observers {
+		o.Transition(ctx, from, i)
+	}
 }
 
 // Init new config
@@ -60,6 +163,8 @@ func NewConfig(repo Repository, pomo, long, short time.Duration) (*IntervalConfi
 		PomoDuration:       25 * time.Minute,
 		LongBreakDuration:  15 * time.Minute,
 		ShortBreakDuration: 5 * time.Minute,
+		Location:           time.Local,
+		Picked:             &PickedTask{},
 	}
 
 	if pomo > 0 {
@@ -76,8 +181,8 @@ func NewConfig(repo Repository, pomo, long, short time.Duration) (*IntervalConfi
 }
 
 // Recognize next category
-func NextCategory(r Repository) (string, error) {
-	last, err := r.Last()
+func NextCategory(ctx context.Context, r Repository) (string, error) {
+	last, err := r.Last(ctx)
 	if err != nil && err == ErrNoIntervals {
 		return PomodoCategory, nil
 	}
@@ -94,7 +199,7 @@ func NextCategory(r Repository) (string, error) {
 		return PomodoCategory, nil
 	}
 
-	breaks, err := r.Breaks(3)
+	breaks, err := r.Breaks(ctx, 3)
 	if err != nil {
 		return "", nil
 	}
@@ -113,55 +218,65 @@ func NextCategory(r Repository) (string, error) {
 }
 
 // Return new interval
-func NewInterval(config *IntervalConfig) (Interval, error) {
+func NewInterval(ctx context.Context, config *IntervalConfig) (Interval, error) {
 	i := Interval{}
 
-	category, err := NextCategory(config.Repo)
+	category, err := NextCategory(ctx, config.Repo)
 	if err != nil {
 		return i, nil
 	}
 
 	i.Category = category
+	i.Project = config.Project
 
 	switch category {
 	case PomodoCategory:
 		i.TimePlanning = config.PomoDuration
+		i.Task = config.Task
+		if t, ok := config.Picked.Get(); ok {
+			i.Task = t.Description
+		}
+		i.Tags = config.Tags
+		config.planAround(&i, time.Now())
 	case LongBreakCategory:
 		i.TimePlanning = config.LongBreakDuration
 	case ShortBreakCategory:
 		i.TimePlanning = config.ShortBreakDuration
 	}
 
-	if i.ID, err = config.Repo.Create(i); err != nil {
+	if i.ID, err = config.Repo.Create(ctx, i); err != nil {
 		return i, err
 	}
 	return i, nil
 }
 
 // Return running, stoped or new interval
-func GetInterval(config *IntervalConfig) (Interval, error) {
+func GetInterval(ctx context.Context, config *IntervalConfig) (Interval, error) {
 	i := Interval{}
 
-	i, err := config.Repo.Last()
+	i, err := config.Repo.Last(ctx)
 	if err != nil && err != ErrNoIntervals {
 		return i, err
 	}
 
-	if err == nil && i.State != StateCanceled && i.State != StateDone {
+	if err == nil && !Ended(i.State) {
 		return i, nil
 	}
 
-	return NewInterval(config)
+	return NewInterval(ctx, config)
 }
 
 type Callback func(Interval)
 
+// Time allowed to record a canceled interval after its context is done
+var CancelTimeout = 2 * time.Second
+
 // Performing action for interval
 func tick(ctx context.Context, config *IntervalConfig, start, periodic, end Callback, id int64) error {
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 
-	i, err := config.Repo.ByID(id)
+	i, err := config.Repo.ByID(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -173,38 +288,75 @@ func tick(ctx context.Context, config *IntervalConfig, start, periodic, end Call
 	for {
 		select {
 		case <-ticker.C:
-			i, err := config.Repo.ByID(id)
-			if err != nil {
-				return err
-			}
-
-			if i.State == StatePaused {
+			// Paused, stopped or finished by somebody else
+			err := config.Repo.Modify(ctx, id, func(cur *Interval) error {
+				if cur.State != StateRunning {
+					return ErrIntervalNotRunning
+				}
+				cur.TimeActual += time.Second
+				i = *cur
+				return nil
+			})
+			if errors.Is(err, ErrIntervalNotRunning) {
 				return nil
 			}
-
-			i.TimeActual += time.Second
-			if err := config.Repo.Update(i); err != nil {
+			if err != nil && ctx.Err() != nil {
+				return cancelInterval(ctx, config, id)
+			}
+			if err != nil {
 				return err
 			}
 			periodic(i)
 		case <-expire:
-			i, err := config.Repo.ByID(id)
+			recordEnd := config.recordHead(true)
+			err := config.Repo.Modify(ctx, id, func(cur *Interval) error {
+				if cur.State != StateRunning {
+					return ErrIntervalNotRunning
+				}
+				cur.State = StateDone
+				recordEnd(cur)
+				i = *cur
+				return nil
+			})
+			if errors.Is(err, ErrIntervalNotRunning) {
+				return nil
+			}
+			if err != nil && ctx.Err() != nil {
+				return cancelInterval(ctx, config, id)
+			}
 			if err != nil {
 				return err
 			}
-			i.State = StateDone
+			config.notify(ctx, StateRunning, i)
 			end(i)
-			return config.Repo.Update(i)
+			return nil
 		case <-ctx.Done():
-			i, err := config.Repo.ByID(id)
-			if err != nil {
-				return err
-			}
+			return cancelInterval(ctx, config, id)
+		}
+	}
+}
 
-			i.State = StateCanceled
-			return config.Repo.Update(i)
+// Mark running interval as canceled once its context is done
+func cancelInterval(ctx context.Context, config *IntervalConfig, id int64) error {
+	// Record the cancellation even though ctx is done
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), CancelTimeout)
+	defer cancel()
+
+	var i Interval
+	recordEnd := config.recordHead(true)
+	err := config.Repo.Modify(ctx, id, func(cur *Interval) error {
+		if cur.State != StateRunning {
+			return nil
 		}
+		cur.State = StateCanceled
+		recordEnd(cur)
+		i = *cur
+		return nil
+	})
+	if err == nil && i.ID != 0 {
+		config.notify(ctx, StateRunning, i)
 	}
+	return err
 }
 
 func (i Interval) Start(ctx context.Context, config *IntervalConfig, start, periodic, end Callback) error {
@@ -212,25 +364,83 @@ func (i Interval) Start(ctx context.Context, config *IntervalConfig, start, peri
 	case StateRunning:
 		return nil
 	case StateNotStarted:
-		i.TimeStart = time.Now()
+		i.TimeStart = time.Now().UTC()
+		config.recordHead(false)(&i)
 		fallthrough
 	case StatePaused:
+		from := i.State
 		i.State = StateRunning
-		if err := config.Repo.Update(i); err != nil {
+		if err := config.Repo.Update(ctx, i); err != nil {
 			return err
 		}
+		config.notify(ctx, from, i)
 		return tick(ctx, config, start, periodic, end, i.ID)
-	case StateCanceled, StateDone:
+	case StateCanceled, StateDone, StateSkipped:
 		return fmt.Errorf("%w: Cannot Start", ErrIntervalCompleted)
 	default:
 		return fmt.Errorf("%w: %d", ErrInvalidState, i.State)
 	}
 }
 
-func (i Interval) Pause(config *IntervalConfig) error {
+func (i Interval) Pause(ctx context.Context, config *IntervalConfig) error {
 	if i.State != StateRunning {
 		return ErrIntervalNotRunning
 	}
-	i.State = StatePaused
-	return config.Repo.Update(i)
+	err := config.Repo.Modify(ctx, i.ID, func(cur *Interval) error {
+		if cur.State != StateRunning {
+			return ErrIntervalNotRunning
+		}
+		cur.State = StatePaused
+		i = *cur
+		return nil
+	})
+	if err == nil {
+		config.notify(ctx, StateRunning, i)
+	}
+	return err
+}
+
+// Cancel the interval, the next one starts over with a pomodoro
+func (i Interval) Stop(ctx context.Context, config *IntervalConfig) error {
+	from := StateNotStarted
+	recordEnd := config.recordHead(true)
+	err := config.Repo.Modify(ctx, i.ID, func(cur *Interval) error {
+		if Ended(cur.State) {
+			return fmt.Errorf("%w: Cannot Stop", ErrIntervalCompleted)
+		}
+		from = cur.State
+		cur.State = StateCanceled
+		recordEnd(cur)
+		i = *cur
+		return nil
+	})
+	if err == nil {
+		config.notify(ctx, from, i)
+	}
+	return err
+}
+
+// End the interval early as skipped, the next one follows the usual
+// sequence
+func (i Interval) Skip(ctx context.Context, config *IntervalConfig) error {
+	from := StateNotStarted
+	recordStart, recordEnd := config.recordHead(false), config.recordHead(true)
+	err := config.Repo.Modify(ctx, i.ID, func(cur *Interval) error {
+		if Ended(cur.State) {
+			return fmt.Errorf("%w: Cannot Skip", ErrIntervalCompleted)
+		}
+		if cur.State == StateNotStarted {
+			cur.TimeStart = time.Now().UTC()
+			recordStart(cur)
+		}
+		from = cur.State
+		cur.State = StateSkipped
+		recordEnd(cur)
+		i = *cur
+		return nil
+	})
+	if err == nil {
+		config.notify(ctx, from, i)
+	}
+	return err
 }
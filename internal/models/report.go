@@ -0,0 +1,175 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	GroupDay      = "day"
+	GroupWeek     = "week"
+	GroupMonth    = "month"
+	GroupCategory = "category"
+	GroupTag      = "tag"
+	GroupProject  = "project"
+
+	// Key of intervals without tags or project when grouping by them
+	NoTag = "(none)"
+)
+
+var ErrInvalidGroup = fmt.Errorf("Invalid report group")
+
+// Totals of the intervals falling into a report group
+type ReportRow struct {
+	Key       string        `json:"key"`
+	Pomodoros int           `json:"pomodoros"`
+	Breaks    int           `json:"breaks"`
+	Canceled  int           `json:"canceled"`
+	Focus     time.Duration `json:"focus"`
+	Break     time.Duration `json:"break"`
+}
+
+func (r *ReportRow) add(i Interval) {
+	if i.Category == PomodoCategory {
+		r.Focus += i.TimeActual
+		switch i.State {
+		case StateDone:
+			r.Pomodoros++
+		case StateCanceled:
+			r.Canceled++
+		}
+		return
+	}
+
+	r.Break += i.TimeActual
+	if i.State == StateDone {
+		r.Breaks++
+	}
+}
+
+type Report struct {
+	Group string `json:"group"`
+	// Configured days covered, From and To included
+	From time.Time   `json:"from"`
+	To   time.Time   `json:"to"`
+	Days int         `json:"days"`
+	Rows []ReportRow `json:"rows"`
+	// Every interval is counted once in Total, even with several tags
+	Total ReportRow `json:"total"`
+}
+
+// Average focus time per day of the report
+func (r Report) FocusPerDay() time.Duration {
+	if r.Days == 0 {
+		return 0
+	}
+	return r.Total.Focus / time.Duration(r.Days)
+}
+
+// Average completed pomodoros per day of the report
+func (r Report) PomodorosPerDay() float64 {
+	if r.Days == 0 {
+		return 0
+	}
+	return float64(r.Total.Pomodoros) / float64(r.Days)
+}
+
+// Return key of the time group containing day
+func groupKey(group string, day time.Time) string {
+	switch group {
+	case GroupWeek:
+		y, w := day.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", y, w)
+	case GroupMonth:
+		return day.Format("2006-01")
+	default:
+		return day.Format("2006-01-02")
+	}
+}
+
+// Return midnight of the calendar date of t
+func date(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// Summarize intervals of the configured days from through to, given
+// by their calendar dates as returned by Day
+func NewReport(ctx context.Context, config *IntervalConfig, from, to time.Time, group string) (Report, error) {
+	r := Report{
+		Group: group,
+		From:  date(from),
+		To:    date(to),
+	}
+	if r.To.Before(r.From) {
+		r.From, r.To = r.To, r.From
+	}
+
+	rows := map[string]*ReportRow{}
+	keys := []string{}
+	row := func(key string) *ReportRow {
+		if _, ok := rows[key]; !ok {
+			rows[key] = &ReportRow{Key: key}
+			keys = append(keys, key)
+		}
+		return rows[key]
+	}
+
+	timeGroup := false
+	switch group {
+	case GroupDay, GroupWeek, GroupMonth:
+		timeGroup = true
+	case GroupCategory, GroupTag, GroupProject:
+	default:
+		return r, fmt.Errorf("%w: %q", ErrInvalidGroup, group)
+	}
+
+	// Time groups list empty periods too, in chronological order
+	for d := r.From; !d.After(r.To); d = d.AddDate(0, 0, 1) {
+		r.Days++
+		if timeGroup {
+			row(groupKey(group, d))
+		}
+	}
+
+	start, _ := config.DateBounds(r.From)
+	_, end := config.DateBounds(r.To)
+	err := config.Repo.Range(ctx, start, end, func(i Interval) error {
+		r.Total.add(i)
+
+		switch group {
+		case GroupCategory:
+			row(i.Category).add(i)
+		case GroupTag:
+			if len(i.Tags) == 0 {
+				row(NoTag).add(i)
+			}
+			for _, t := range i.Tags {
+				row(t).add(i)
+			}
+		case GroupProject:
+			if i.Project == "" {
+				row(NoTag).add(i)
+			} else {
+				row(i.Project).add(i)
+			}
+		default:
+			row(groupKey(group, config.Day(i.TimeStart))).add(i)
+		}
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+
+	if !timeGroup {
+		sort.Strings(keys)
+	}
+	for _, k := range keys {
+		r.Rows = append(r.Rows, *rows[k])
+	}
+	r.Total.Key = "Total"
+	return r, nil
+}
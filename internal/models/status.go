@@ -0,0 +1,51 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Snapshot of the timer for status lines and prompts
+type Status struct {
+	ID        int64         `json:"id"`
+	Category  string        `json:"category"`
+	State     int           `json:"state"`
+	Planned   time.Duration `json:"planned"`
+	Remaining time.Duration `json:"remaining"`
+	// Pomodoros completed today
+	Today int `json:"today"`
+}
+
+// Is the interval running or paused
+func (s Status) Active() bool {
+	return s.State == StateRunning || s.State == StatePaused
+}
+
+// Return status of the last interval
+func CurrentStatus(ctx context.Context, config *IntervalConfig, now time.Time) (Status, error) {
+	s := Status{}
+
+	today, err := DailyPomodoros(ctx, now, config)
+	if err != nil {
+		return s, err
+	}
+	s.Today = today
+
+	i, err := config.Repo.Last(ctx)
+	if errors.Is(err, ErrNoIntervals) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+
+	s.ID = i.ID
+	s.Category = i.Category
+	s.State = i.State
+	s.Planned = i.TimePlanning
+	if s.Active() || s.State == StateNotStarted {
+		s.Remaining = i.TimePlanning - i.TimeActual
+	}
+	return s, nil
+}
@@ -1,16 +1,19 @@
 package models
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
 
-func DailySummary(day time.Time, config *IntervalConfig) ([]time.Duration, error) {
-	dPromo, err := config.Repo.CategorySummary(day, PomodoCategory)
+func DailySummary(ctx context.Context, day time.Time, config *IntervalConfig) ([]time.Duration, error) {
+	start, end := config.DayBounds(day)
+
+	dPromo, err := config.Repo.CategorySummary(ctx, start, end, PomodoCategory)
 	if err != nil {
 		return nil, err
 	}
-	dBreaks, err := config.Repo.CategorySummary(day, "%Break")
+	dBreaks, err := config.Repo.CategorySummary(ctx, start, end, "%Break")
 	if err != nil {
 		return nil, err
 	}
@@ -22,13 +25,27 @@ func DailySummary(day time.Time, config *IntervalConfig) ([]time.Duration, error
 
 }
 
+// Count pomodoros completed on the configured day containing day
+func DailyPomodoros(ctx context.Context, day time.Time, config *IntervalConfig) (int, error) {
+	start, end := config.DayBounds(day)
+
+	n := 0
+	err := config.Repo.Range(ctx, start, end, func(i Interval) error {
+		if i.Category == PomodoCategory && i.State == StateDone {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
 type LineSeries struct {
 	Name   string
 	Labels map[int]string
 	Values []float64
 }
 
-func RangeSummary(start time.Time, n int, config *IntervalConfig) ([]LineSeries, error) {
+func RangeSummary(ctx context.Context, start time.Time, n int, config *IntervalConfig) ([]LineSeries, error) {
 	pomodoroSeries := LineSeries{
 		Name:   "Pomodoro",
 		Labels: make(map[int]string),
@@ -40,9 +57,10 @@ func RangeSummary(start time.Time, n int, config *IntervalConfig) ([]LineSeries,
 		Values: make([]float64, n),
 	}
 
+	start, _ = config.DayBounds(start)
 	for i := 0; i < n; i++ {
 		day := start.AddDate(0, 0, -i)
-		ds, err := DailySummary(day, config)
+		ds, err := DailySummary(ctx, day, config)
 		if err != nil {
 			return nil, err
 		}
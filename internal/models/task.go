@@ -0,0 +1,54 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Task of a task manager pomodoros can be spent on
+type Task struct {
+	// Identity in the task manager, like a UUID
+	ID          string
+	Description string
+	Project     string
+	Tags        []string
+}
+
+// TaskSource lists the open tasks of a task manager, most urgent first
+type TaskSource interface {
+	Tasks() ([]Task, error)
+}
+
+// PickedTask is the task picked in the dashboard for the next pomodoros,
+// safe for concurrent use. Until one is picked they get the configured
+// task.
+type PickedTask struct {
+	mu     sync.Mutex
+	task   Task
+	picked bool
+}
+
+// Pick t for the next pomodoros, an empty one for none
+func (p *PickedTask) Pick(t Task) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.task, p.picked = t, true
+}
+
+// Get returns the picked task, false if none was picked
+func (p *PickedTask) Get() (Task, bool) {
+	if p == nil {
+		return Task{}, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.task, p.picked
+}
+
+var ErrNoTask = fmt.Errorf("No such task")
+
+// TaskCompleter is implemented by task sources able to mark a task
+// done, found by ID or else by description
+type TaskCompleter interface {
+	Complete(t Task) error
+}
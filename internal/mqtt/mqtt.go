@@ -0,0 +1,250 @@
+// Package mqtt publishes the timer to an MQTT broker and takes commands
+// from it, e.g. for focus lights driven by home automation.
+//
+// Retained topics under the prefix, pomodoro/<user> by default, follow
+// every transition of the timer:
+//
+//	<prefix>/state      running, paused, done, canceled or notstarted
+//	<prefix>/remaining  seconds left in the interval
+//	<prefix>/category   Pomodoro, ShortBreak or LongBreak
+//	<prefix>/status     the status as JSON, as served by the HTTP API
+//	<prefix>/online     true while connected, false as the last will
+//
+// Remaining and status are also published every second with Ticks.
+// Payloads start, resume, pause, stop and skip on <prefix>/command
+// control the timer.
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+const (
+	// Limit of the last will and of waiting for deliveries on close
+	closeTimeout = 2 * time.Second
+	// Waiting for a publication before it is logged as failed
+	publishTimeout = 10 * time.Second
+)
+
+type Config struct {
+	// URL of the broker, e.g. tcp://localhost:1883 or ssl://host:8883
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// Prefix of the topics, DefaultTopic() when empty
+	Topic string
+	QoS   byte
+	// Publish the remaining time every second
+	Ticks bool
+	// PEM files of the CA to verify the broker with, default the system
+	// roots, and of the client certificate
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	// Skip verification of the broker certificate
+	Insecure bool
+}
+
+// Return pomodoro/<user>
+func DefaultTopic() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if name == "" {
+		name = "default"
+	}
+	// Wildcards and levels are not allowed in a user name
+	name = strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(name)
+	return "pomodoro/" + name
+}
+
+// Check the broker URL and topic without connecting
+func (c Config) Validate() error {
+	u, err := url.Parse(c.Broker)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss":
+	default:
+		return fmt.Errorf("broker %q: want tcp://, ssl://, ws:// or wss://", c.Broker)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("broker %q: missing host", c.Broker)
+	}
+	if strings.ContainsAny(c.Topic, "+#") {
+		return fmt.Errorf("topic %q: wildcards not allowed", c.Topic)
+	}
+	if c.QoS > 2 {
+		return fmt.Errorf("qos %d: want 0, 1 or 2", c.QoS)
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("client certificate needs both cert and key")
+	}
+	return nil
+}
+
+func (c Config) tlsConfig() (*tls.Config, error) {
+	t := &tls.Config{InsecureSkipVerify: c.Insecure}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		t.RootCAs = x509.NewCertPool()
+		if !t.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates", c.CAFile)
+		}
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		t.Certificates = []tls.Certificate{cert}
+	}
+	return t, nil
+}
+
+// Bridge connects the timer to the broker
+type Bridge struct {
+	config Config
+	timer  daemon.Timer
+	client paho.Client
+
+	mu sync.Mutex
+	// Last status published, again on reconnects
+	last *models.Status
+}
+
+func New(config Config, timer daemon.Timer) (*Bridge, error) {
+	if config.Topic == "" {
+		config.Topic = DefaultTopic()
+	}
+	config.Topic = strings.TrimSuffix(config.Topic, "/")
+	if config.ClientID == "" {
+		config.ClientID = fmt.Sprintf("pomodoro-go-%d", os.Getpid())
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bridge{config: config, timer: timer}
+	opts := paho.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(config.ClientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetTLSConfig(tlsConfig).
+		SetWill(b.topic("online"), "false", config.QoS, true).
+		SetConnectRetry(true).
+		SetAutoReconnect(true).
+		SetOnConnectHandler(b.connected)
+	b.client = paho.NewClient(opts)
+	return b, nil
+}
+
+func (b *Bridge) topic(name string) string {
+	return b.config.Topic + "/" + name
+}
+
+// Run publishes the timer and takes commands until ctx is done
+func (b *Bridge) Run(ctx context.Context) error {
+	// Retried in the background until the broker is up
+	b.client.Connect()
+	defer func() {
+		t := b.client.Publish(b.topic("online"), b.config.QoS, true, "false")
+		t.WaitTimeout(closeTimeout)
+		b.client.Disconnect(uint(closeTimeout.Milliseconds()))
+	}()
+
+	return b.timer.Subscribe(ctx, func(ev daemon.Event) {
+		if ev.Name == daemon.EventTick && !b.config.Ticks {
+			return
+		}
+		b.mu.Lock()
+		b.last = &ev.Status
+		b.mu.Unlock()
+		b.publish(ev)
+	})
+}
+
+// Announce the bridge and subscribe to commands on every connection,
+// the broker forgets the subscriptions of clean sessions
+func (b *Bridge) connected(c paho.Client) {
+	b.send("online", "true")
+	b.mu.Lock()
+	last := b.last
+	b.mu.Unlock()
+	if last != nil {
+		b.publish(daemon.Event{Name: daemon.EventStatus, Status: *last})
+	}
+	t := c.Subscribe(b.topic("command"), b.config.QoS, b.command)
+	go func() {
+		if t.WaitTimeout(publishTimeout) && t.Error() != nil {
+			log.Printf("mqtt: subscribe: %v", t.Error())
+		}
+	}()
+}
+
+func (b *Bridge) command(_ paho.Client, msg paho.Message) {
+	cmd := strings.ToLower(strings.TrimSpace(string(msg.Payload())))
+	switch cmd {
+	case daemon.CmdStart, daemon.CmdResume, daemon.CmdPause, daemon.CmdStop, daemon.CmdSkip:
+	default:
+		log.Printf("mqtt: %s: unknown command %q", msg.Topic(), cmd)
+		return
+	}
+	if _, err := b.timer.Do(context.Background(), cmd); err != nil {
+		log.Printf("mqtt: %s: %v", cmd, err)
+	}
+}
+
+func (b *Bridge) publish(ev daemon.Event) {
+	st := ev.Status
+	status, err := json.Marshal(st)
+	if err != nil {
+		log.Printf("mqtt: %v", err)
+		return
+	}
+	b.send("remaining", strconv.FormatInt(int64(st.Remaining.Round(time.Second).Seconds()), 10))
+	b.send("status", string(status))
+	if ev.Name == daemon.EventTick {
+		return
+	}
+	b.send("state", strings.ToLower(models.StateName(st.State)))
+	b.send("category", st.Category)
+}
+
+// Publish retained payload to topic name under the prefix, without
+// waiting for the broker
+func (b *Bridge) send(name, payload string) {
+	t := b.client.Publish(b.topic(name), b.config.QoS, true, payload)
+	go func() {
+		if t.WaitTimeout(publishTimeout) && t.Error() != nil {
+			log.Printf("mqtt: publish %s: %v", b.topic(name), t.Error())
+		}
+	}()
+}
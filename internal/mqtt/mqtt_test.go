@@ -0,0 +1,228 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	broker "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/xor111xor/pomodoro-go/internal/daemon"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/repository"
+)
+
+// Write a self-signed certificate of 127.0.0.1 to dir, return the TLS
+// config of the broker and the path of the certificate
+func selfSigned(t *testing.T, dir string) (*tls.Config, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "broker"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, path
+}
+
+// Start a broker on a TLS listener, accepting user pomo with password
+// secret
+func startBroker(t *testing.T, tlsConfig *tls.Config) (*broker.Server, string) {
+	t.Helper()
+	b := broker.New(&broker.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	err := b.AddHook(new(auth.Hook), &auth.Options{Ledger: &auth.Ledger{
+		Auth: auth.AuthRules{{Username: "pomo", Password: "secret", Allow: true}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddListener(listeners.NewNet("t", tls.NewListener(l, tlsConfig))); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b, "ssl://" + l.Addr().String()
+}
+
+// Latest payloads of the topics
+type topics struct {
+	mu       sync.Mutex
+	payloads map[string]string
+}
+
+func (tp *topics) wait(t *testing.T, topic, exp string) {
+	t.Helper()
+	var got string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		tp.mu.Lock()
+		got = tp.payloads[topic]
+		tp.mu.Unlock()
+		if got == exp {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected %q on %s, got %q", exp, topic, got)
+}
+
+func TestBridge(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tlsConfig, ca := selfSigned(t, t.TempDir())
+	b, url := startBroker(t, tlsConfig)
+	tp := &topics{payloads: map[string]string{}}
+	err := b.Subscribe("pomodoro/test/#", 1, func(_ *broker.Client, _ packets.Subscription, pk packets.Packet) {
+		tp.mu.Lock()
+		defer tp.mu.Unlock()
+		tp.payloads[pk.TopicName] = string(pk.Payload)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := models.NewConfig(repository.NewInMemoryRepo(), time.Minute, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timer := daemon.NewServer(config)
+	defer timer.Close()
+
+	bridge, err := New(Config{
+		Broker:   url,
+		Username: "pomo",
+		Password: "secret",
+		Topic:    "pomodoro/test/",
+		QoS:      1,
+		CAFile:   ca,
+	}, timer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- bridge.Run(ctx) }()
+
+	tp.wait(t, "pomodoro/test/online", "true")
+	tp.wait(t, "pomodoro/test/state", "notstarted")
+
+	t.Run("Publish", func(t *testing.T) {
+		if _, err := timer.Do(ctx, daemon.CmdStart); err != nil {
+			t.Fatal(err)
+		}
+		tp.wait(t, "pomodoro/test/state", "running")
+		tp.wait(t, "pomodoro/test/category", models.PomodoCategory)
+		tp.wait(t, "pomodoro/test/remaining", "60")
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		if err := b.Publish("pomodoro/test/command", []byte("pause\n"), false, 1); err != nil {
+			t.Fatal(err)
+		}
+		tp.wait(t, "pomodoro/test/state", "paused")
+		s, err := timer.Do(ctx, daemon.CmdStatus)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.State != models.StatePaused {
+			t.Errorf("Expected paused timer, got %s", models.StateName(s.State))
+		}
+	})
+
+	t.Run("Retained", func(t *testing.T) {
+		retained := map[string]string{}
+		err := b.Subscribe("pomodoro/test/+", 2, func(_ *broker.Client, _ packets.Subscription, pk packets.Packet) {
+			tp.mu.Lock()
+			defer tp.mu.Unlock()
+			retained[pk.TopicName] = string(pk.Payload)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tp.mu.Lock()
+		defer tp.mu.Unlock()
+		if retained["pomodoro/test/state"] != "paused" {
+			t.Errorf("Expected retained state for new subscribers, got %v", retained)
+		}
+	})
+
+	cancel()
+	if err := <-done; err != nil && err != context.Canceled {
+		t.Fatal(err)
+	}
+	tp.wait(t, "pomodoro/test/online", "false")
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{"TCP", Config{Broker: "tcp://localhost:1883"}, true},
+		{"WebSocket", Config{Broker: "wss://example.com/mqtt"}, true},
+		{"Scheme", Config{Broker: "http://localhost:1883"}, false},
+		{"Host", Config{Broker: "tcp://"}, false},
+		{"Wildcard", Config{Broker: "tcp://localhost:1883", Topic: "pomodoro/#"}, false},
+		{"QoS", Config{Broker: "tcp://localhost:1883", QoS: 3}, false},
+		{"Key", Config{Broker: "ssl://localhost:8883", CertFile: "client.pem"}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.valid && err != nil {
+				t.Errorf("Expected valid config, got %q", err)
+			}
+			if !tc.valid && err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,345 @@
+// Package notify shows desktop notifications over D-Bus when intervals
+// are done.
+//
+// Notifications go to org.freedesktop.Notifications on the session bus.
+// In processes which can start intervals the notifications carry "Start"
+// and "Snooze" actions: start begins the next interval and snooze shows
+// the notification again later, unless an interval was started since.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+const (
+	busName = "org.freedesktop.Notifications"
+	busPath = "/org/freedesktop/Notifications"
+	appName = "pomodoro-go"
+
+	actionStart  = "start"
+	actionSnooze = "snooze"
+
+	DefaultSnooze = 5 * time.Minute
+)
+
+// Title and body templates of a notification, executed with Data
+type Message struct {
+	Title string `mapstructure:"title"`
+	Body  string `mapstructure:"body"`
+}
+
+// Data of the message templates
+type Data struct {
+	Category string
+	Task     string
+	Tags     []string
+	Actual   time.Duration
+	// Whole minutes of Actual
+	Minutes int
+	// Category of the next interval
+	Next string
+}
+
+var (
+	DefaultPomodoro = Message{
+		Title: "Pomodoro done",
+		Body:  "{{.Minutes}} minutes of focus{{if .Task}} on {{.Task}}{{end}}, time for a break.",
+	}
+	DefaultBreak = Message{
+		Title: "Break over",
+		Body:  "Ready for the next pomodoro?",
+	}
+)
+
+type Config struct {
+	// Shown when a pomodoro is done
+	Pomodoro Message
+	// Shown when a break is done
+	Break Message
+	// Delay of the snooze action, zero for DefaultSnooze
+	Snooze time.Duration
+}
+
+type message struct {
+	title *template.Template
+	body  *template.Template
+}
+
+func parse(m Message) (message, error) {
+	title, err := template.New("title").Parse(m.Title)
+	if err != nil {
+		return message{}, err
+	}
+	body, err := template.New("body").Parse(m.Body)
+	if err != nil {
+		return message{}, err
+	}
+	return message{title, body}, nil
+}
+
+func (m message) render(d Data) (string, string, error) {
+	title, body := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := m.title.Execute(title, d); err != nil {
+		return "", "", err
+	}
+	if err := m.body.Execute(body, d); err != nil {
+		return "", "", err
+	}
+	return title.String(), body.String(), nil
+}
+
+// Check the message templates, rendering them with sample data
+func (m Message) Validate() error {
+	msg, err := parse(m)
+	if err != nil {
+		return err
+	}
+	_, _, err = msg.render(Data{Category: models.PomodoCategory, Next: models.ShortBreakCategory})
+	return err
+}
+
+// Notifier shows the notifications of the transitions it observes
+type Notifier struct {
+	config   *models.IntervalConfig
+	pomodoro message
+	brk      message
+	snooze   time.Duration
+	onError  func(error)
+	wg       sync.WaitGroup
+
+	mu     sync.Mutex
+	start  func(context.Context) error
+	conn   *dbus.Conn
+	shown  map[uint32]models.Interval
+	timers []*time.Timer
+	closed bool
+}
+
+// New notifier for the intervals of config, failures go to onError
+func New(c Config, config *models.IntervalConfig, onError func(error)) (*Notifier, error) {
+	pomodoro, err := parse(c.Pomodoro)
+	if err != nil {
+		return nil, err
+	}
+	brk, err := parse(c.Break)
+	if err != nil {
+		return nil, err
+	}
+	if c.Snooze == 0 {
+		c.Snooze = DefaultSnooze
+	}
+	return &Notifier{
+		config:   config,
+		pomodoro: pomodoro,
+		brk:      brk,
+		snooze:   c.Snooze,
+		onError:  onError,
+		shown:    map[uint32]models.Interval{},
+	}, nil
+}
+
+// OnStart enables the actions, start begins the next interval
+func (n *Notifier) OnStart(start func(context.Context) error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.start = start
+}
+
+// Transition notifies of intervals which ran out
+func (n *Notifier) Transition(ctx context.Context, from int, i models.Interval) {
+	if from != models.StateRunning || i.State != models.StateDone {
+		return
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		if err := n.notify(context.WithoutCancel(ctx), i); err != nil {
+			n.onError(fmt.Errorf("notify: %w", err))
+		}
+	}()
+}
+
+func (n *Notifier) notify(ctx context.Context, i models.Interval) error {
+	next, err := models.NextCategory(ctx, n.config.Repo)
+	if err != nil {
+		return err
+	}
+	msg, label := n.pomodoro, "Start break"
+	if i.Category != models.PomodoCategory {
+		msg, label = n.brk, "Start pomodoro"
+	}
+	title, body, err := msg.render(Data{
+		Category: i.Category,
+		Task:     i.Task,
+		Tags:     i.Tags,
+		Actual:   i.TimeActual,
+		Minutes:  int(i.TimeActual.Minutes()),
+		Next:     next,
+	})
+	if err != nil {
+		return err
+	}
+
+	conn, err := n.connect()
+	if err != nil {
+		return err
+	}
+	actions := []string{}
+	n.mu.Lock()
+	if n.start != nil {
+		actions = []string{actionStart, label, actionSnooze, "Snooze " + minutes(n.snooze)}
+	}
+	n.mu.Unlock()
+
+	var id uint32
+	err = conn.Object(busName, busPath).CallWithContext(ctx, busName+".Notify", 0,
+		appName, uint32(0), "", title, body, actions,
+		map[string]dbus.Variant{}, int32(-1)).Store(&id)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(actions) > 0 {
+		n.shown[id] = i
+	}
+	return nil
+}
+
+// Connect to the session bus on first use and follow the actions
+func (n *Notifier) connect() (*dbus.Conn, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return nil, fmt.Errorf("notifier closed")
+	}
+	if n.conn != nil {
+		return n.conn, nil
+	}
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(busPath),
+		dbus.WithMatchInterface(busName),
+	); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	go n.follow(signals)
+
+	n.conn = conn
+	return conn, nil
+}
+
+// Handle signals until the connection is closed
+func (n *Notifier) follow(signals <-chan *dbus.Signal) {
+	for sig := range signals {
+		switch sig.Name {
+		case busName + ".ActionInvoked":
+			var id uint32
+			var action string
+			if err := dbus.Store(sig.Body, &id, &action); err == nil {
+				n.action(id, action)
+			}
+		case busName + ".NotificationClosed":
+			var id, reason uint32
+			if err := dbus.Store(sig.Body, &id, &reason); err == nil {
+				n.mu.Lock()
+				delete(n.shown, id)
+				n.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (n *Notifier) action(id uint32, action string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	i, ok := n.shown[id]
+	if !ok || n.closed {
+		return
+	}
+	delete(n.shown, id)
+
+	switch action {
+	case actionStart:
+		go func(start func(context.Context) error) {
+			if err := start(context.Background()); err != nil {
+				n.onError(fmt.Errorf("notify: start: %w", err))
+			}
+		}(n.start)
+	case actionSnooze:
+		n.timers = append(n.timers, time.AfterFunc(n.snooze, func() { n.remind(i) }))
+	}
+}
+
+// Notify of i again unless an interval was started since
+func (n *Notifier) remind(i models.Interval) {
+	n.mu.Lock()
+	closed := n.closed
+	n.mu.Unlock()
+	if closed {
+		return
+	}
+
+	ctx := context.Background()
+	last, err := n.config.Repo.Last(ctx)
+	if err != nil {
+		n.onError(fmt.Errorf("notify: %w", err))
+		return
+	}
+	if last.ID != i.ID && last.State != models.StateNotStarted {
+		return
+	}
+	if err := n.notify(ctx, i); err != nil {
+		n.onError(fmt.Errorf("notify: %w", err))
+	}
+}
+
+// Wait until the notifications of the transitions are shown, actions
+// taken later are not waited for
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+// Close drops pending reminders and the connection to the bus
+func (n *Notifier) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closed = true
+	for _, t := range n.timers {
+		t.Stop()
+	}
+	if n.conn == nil {
+		return nil
+	}
+	return n.conn.Close()
+}
+
+// Format d like 5m or 1h30m, dropping zero units
+func minutes(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,246 @@
+//go:build !windows
+
+package notify
+
+import (
+	"bufio"
+	"context"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/repository"
+)
+
+// Start a private session bus for the test
+func sessionBus(t *testing.T) {
+	t.Helper()
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+	cmd := exec.Command(path, "--session", "--nofork", "--print-address=1")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", strings.TrimSpace(addr))
+}
+
+type notification struct {
+	title, body string
+	actions     []string
+}
+
+// Notification server recording the notifications
+type server struct {
+	conn  *dbus.Conn
+	mu    sync.Mutex
+	shown []notification
+	ch    chan uint32
+}
+
+func (s *server) Notify(app string, replaces uint32, icon, title, body string,
+	actions []string, hints map[string]dbus.Variant, timeout int32) (uint32, *dbus.Error) {
+	s.mu.Lock()
+	s.shown = append(s.shown, notification{title, body, actions})
+	id := uint32(len(s.shown))
+	s.mu.Unlock()
+	s.ch <- id
+	return id, nil
+}
+
+func (s *server) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.shown)
+}
+
+func (s *server) invoke(t *testing.T, id uint32, action string) {
+	t.Helper()
+	if err := s.conn.Emit(busPath, busName+".ActionInvoked", id, action); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (s *server) wait(t *testing.T) notification {
+	t.Helper()
+	select {
+	case id := <-s.ch:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.shown[id-1]
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for notification")
+	}
+	return notification{}
+}
+
+// Is the notification with id waiting for actions
+func (n *Notifier) showing(id uint32) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, ok := n.shown[id]
+	return ok
+}
+
+func newServer(t *testing.T) *server {
+	t.Helper()
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	s := &server{conn: conn, ch: make(chan uint32, 8)}
+	if err := conn.Export(s, busPath, busName); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := conn.RequestName(busName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("Expected bus name, got %v, %v", reply, err)
+	}
+	return s
+}
+
+func TestNotifier(t *testing.T) {
+	sessionBus(t)
+	s := newServer(t)
+	ctx := context.Background()
+
+	config, err := models.NewConfig(repository.NewInMemoryRepo(), 25*time.Minute, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := models.Interval{
+		Category:     models.PomodoCategory,
+		State:        models.StateDone,
+		TimeStart:    time.Now().Add(-25 * time.Minute),
+		TimePlanning: 25 * time.Minute,
+		TimeActual:   25 * time.Minute,
+		Task:         "Write report",
+	}
+	if i.ID, err = config.Repo.Create(ctx, i); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 8)
+	n, err := New(Config{
+		Pomodoro: DefaultPomodoro,
+		Break:    Message{Title: "{{.Category}} over", Body: "Next: {{.Next}}"},
+		Snooze:   100 * time.Millisecond,
+	}, config, func(err error) { errs <- err })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+	started := make(chan struct{}, 1)
+	n.OnStart(func(context.Context) error {
+		started <- struct{}{}
+		return nil
+	})
+
+	t.Run("Skipped", func(t *testing.T) {
+		skipped := i
+		skipped.State = models.StateSkipped
+		skipped.TimeActual = time.Minute
+		n.Transition(ctx, models.StateRunning, skipped)
+		n.Transition(ctx, models.StatePaused, i)
+		n.Wait()
+		if n := s.count(); n != 0 {
+			t.Errorf("Expected no notifications, got %d", n)
+		}
+	})
+
+	t.Run("Done", func(t *testing.T) {
+		n.Transition(ctx, models.StateRunning, i)
+		got := s.wait(t)
+		exp := notification{
+			title:   "Pomodoro done",
+			body:    "25 minutes of focus on Write report, time for a break.",
+			actions: []string{actionStart, "Start break", actionSnooze, "Snooze 100ms"},
+		}
+		if got.title != exp.title || got.body != exp.body ||
+			strings.Join(got.actions, ",") != strings.Join(exp.actions, ",") {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("Snooze", func(t *testing.T) {
+		// Actions follow the reply of the notification
+		n.Wait()
+		s.invoke(t, 1, actionSnooze)
+		if got := s.wait(t); got.title != "Pomodoro done" {
+			t.Errorf("Expected notification again, got %v", got)
+		}
+	})
+
+	t.Run("Start", func(t *testing.T) {
+		for deadline := time.Now().Add(5 * time.Second); !n.showing(2); {
+			if time.Now().After(deadline) {
+				t.Fatal("Timeout waiting for reminder")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		s.invoke(t, 2, actionStart)
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timeout waiting for start")
+		}
+		// Actions of a notification are taken once
+		s.invoke(t, 2, actionStart)
+		time.Sleep(100 * time.Millisecond)
+		if len(started) != 0 {
+			t.Error("Expected a single start")
+		}
+	})
+
+	t.Run("Break", func(t *testing.T) {
+		b := models.Interval{
+			ID:           i.ID + 1,
+			Category:     models.ShortBreakCategory,
+			State:        models.StateDone,
+			TimePlanning: 5 * time.Minute,
+			TimeActual:   5*time.Minute - time.Second,
+		}
+		n.Transition(ctx, models.StateRunning, b)
+		got := s.wait(t)
+		if got.title != "ShortBreak over" || got.body != "Next: ShortBreak" ||
+			got.actions[1] != "Start pomodoro" {
+			t.Errorf("Expected break notification, got %v", got)
+		}
+	})
+
+	n.Wait()
+	if len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", <-errs)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, m := range []Message{DefaultPomodoro, DefaultBreak} {
+		if err := m.Validate(); err != nil {
+			t.Errorf("Expected valid %v, got %s", m, err)
+		}
+	}
+	for _, m := range []Message{{Title: "{{.Task"}, {Body: "{{.Nope}}"}} {
+		if err := m.Validate(); err == nil {
+			t.Errorf("Expected invalid %v", m)
+		}
+	}
+}
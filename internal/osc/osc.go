@@ -0,0 +1,155 @@
+// Package osc reports the timer through terminal escape sequences: the
+// bell, OSC 9 and OSC 777 notifications and OSC 0 window titles.
+//
+// They reach the terminal emulator without any daemon, also from a
+// background tmux window: tmux flags the window on the bell, keeps the
+// title as the pane title and passes the notifications through when
+// allow-passthrough is on.
+package osc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	appName = "pomodoro-go"
+
+	// Save and restore the title on xterm-like terminals
+	pushTitle = "\x1b[22;0t"
+	popTitle  = "\x1b[23;0t"
+)
+
+// Config enables each kind of sequence
+type Config struct {
+	// Ring the bell when an interval is done
+	Bell bool `mapstructure:"bell"`
+	// Notify when an interval is done with OSC 9 (iTerm2, WezTerm,
+	// kitty, Windows Terminal, ...)
+	OSC9 bool `mapstructure:"osc9"`
+	// Notify when an interval is done with OSC 777 (urxvt, foot,
+	// VTE-based terminals, ...)
+	OSC777 bool `mapstructure:"osc777"`
+	// Show the remaining time in the window and tab title
+	Title bool `mapstructure:"title"`
+}
+
+// Writer writes the enabled sequences to the terminal
+type Writer struct {
+	config Config
+	out    io.Writer
+	tmux   bool
+
+	mu     sync.Mutex
+	title  string
+	pushed bool
+}
+
+// New writer to the terminal out
+func New(config Config, out io.Writer) *Writer {
+	return &Writer{
+		config: config,
+		out:    out,
+		tmux:   os.Getenv("TMUX") != "",
+	}
+}
+
+// Running interval with remaining time left
+func (w *Writer) Running(category string, remaining time.Duration) {
+	w.setTitle(clock(remaining) + " " + category)
+}
+
+// Paused interval with remaining time left
+func (w *Writer) Paused(category string, remaining time.Duration) {
+	w.setTitle(clock(remaining) + " " + category + " (paused)")
+}
+
+// Idle after an interval was stopped or skipped
+func (w *Writer) Idle() {
+	w.setTitle(appName)
+}
+
+// Done interval of category, ran out of time
+func (w *Writer) Done(category string) {
+	msg := category + " done"
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.config.Bell {
+		io.WriteString(w.out, "\a")
+	}
+	if w.config.OSC9 {
+		w.passthrough("\x1b]9;" + clean(msg) + "\a")
+	}
+	if w.config.OSC777 {
+		w.passthrough("\x1b]777;notify;" + appName + ";" + clean(msg) + "\a")
+	}
+	w.writeTitle(msg)
+}
+
+// Close restores the title of the terminal
+func (w *Writer) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.pushed {
+		io.WriteString(w.out, popTitle)
+		w.pushed = false
+	}
+}
+
+func (w *Writer) setTitle(title string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writeTitle(title)
+}
+
+// Write title unless it is shown already
+func (w *Writer) writeTitle(title string) {
+	if !w.config.Title || title == w.title {
+		return
+	}
+	seq := "\x1b]0;" + clean(title) + "\a"
+	if !w.pushed {
+		seq = pushTitle + seq
+		w.pushed = true
+	}
+	io.WriteString(w.out, seq)
+	w.title = title
+}
+
+// Inside tmux notifications need a DCS passthrough with doubled escapes
+func (w *Writer) passthrough(seq string) {
+	if w.tmux {
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	io.WriteString(w.out, seq)
+}
+
+// Drop control characters, which would end the sequence, and the field
+// separator of OSC 777
+func clean(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0) {
+			return -1
+		}
+		if r == ';' {
+			return ','
+		}
+		return r
+	}, s)
+}
+
+// Format d as 4:05 or 1:04:05
+func clock(d time.Duration) string {
+	s := int(d.Round(time.Second).Seconds())
+	if s < 0 {
+		s = 0
+	}
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
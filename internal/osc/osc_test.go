@@ -0,0 +1,57 @@
+package osc
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config Config
+		tmux   string
+		calls  func(w *Writer)
+		exp    string
+	}{
+		{"Disabled", Config{}, "", func(w *Writer) {
+			w.Running("Pomodoro", time.Minute)
+			w.Done("Pomodoro")
+			w.Close()
+		}, ""},
+		{"Title", Config{Title: true}, "", func(w *Writer) {
+			w.Running("Pomodoro", 25*time.Minute)
+			w.Running("Pomodoro", 25*time.Minute)
+			w.Paused("Pomodoro", 64*time.Second+400*time.Millisecond)
+			w.Running("LongBreak", 2*time.Hour)
+			w.Idle()
+			w.Close()
+		}, "\x1b[22;0t\x1b]0;25:00 Pomodoro\a" +
+			"\x1b]0;1:04 Pomodoro (paused)\a" +
+			"\x1b]0;2:00:00 LongBreak\a" +
+			"\x1b]0;pomodoro-go\a" +
+			"\x1b[23;0t"},
+		{"Done", Config{Bell: true, OSC9: true, OSC777: true}, "", func(w *Writer) {
+			w.Done("Pomodoro")
+		}, "\a\x1b]9;Pomodoro done\a\x1b]777;notify;pomodoro-go;Pomodoro done\a"},
+		{"Tmux", Config{Bell: true, OSC9: true, Title: true}, "/tmp/tmux-0/default,1,0", func(w *Writer) {
+			w.Done("ShortBreak")
+		}, "\a\x1bPtmux;\x1b\x1b]9;ShortBreak done\a\x1b\\" +
+			"\x1b[22;0t\x1b]0;ShortBreak done\a"},
+		{"Clean", Config{OSC777: true}, "", func(w *Writer) {
+			w.Done("a;b\x1b\a")
+		}, "\x1b]777;notify;pomodoro-go;a,b done\a"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("TMUX", tc.tmux)
+			out := &bytes.Buffer{}
+			w := New(tc.config, out)
+			tc.calls(w)
+			if out.String() != tc.exp {
+				t.Errorf("Expected %q, got %q", tc.exp, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package internal_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+func TestNewReport(t *testing.T) {
+	ctx := context.Background()
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config, err := models.NewConfig(repo, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Location = time.UTC
+	config.DayStart = 4 * time.Hour
+
+	// Monday 2023-09-04 to Sunday 2023-09-10 form ISO week 36
+	day := func(d, h int) time.Time {
+		return time.Date(2023, 9, d, h, 0, 0, 0, time.UTC)
+	}
+	for _, i := range []models.Interval{
+		{Category: models.PomodoCategory, State: models.StateDone, TimeStart: day(4, 10),
+			TimeActual: 25 * time.Minute, Tags: []string{"work", "docs"}, Project: "pomodoro-go"},
+		{Category: models.ShortBreakCategory, State: models.StateDone, TimeStart: day(4, 11),
+			TimeActual: 5 * time.Minute, Project: "pomodoro-go"},
+		// Before day start, counted on the 4th
+		{Category: models.PomodoCategory, State: models.StateDone, TimeStart: day(5, 2),
+			TimeActual: 25 * time.Minute, Tags: []string{"work"}, Project: "site"},
+		{Category: models.PomodoCategory, State: models.StateCanceled, TimeStart: day(6, 10),
+			TimeActual: 10 * time.Minute},
+		// Skipped pomodoros are not counted
+		{Category: models.PomodoCategory, State: models.StateSkipped, TimeStart: day(6, 11)},
+		{Category: models.PomodoCategory, State: models.StateDone, TimeStart: day(11, 10),
+			TimeActual: 25 * time.Minute},
+		// Outside the range
+		{Category: models.PomodoCategory, State: models.StateDone, TimeStart: day(20, 10),
+			TimeActual: 25 * time.Minute},
+	} {
+		if _, err := repo.Create(ctx, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		group string
+		keys  []string
+		pomos []int
+	}{
+		{models.GroupDay,
+			[]string{"2023-09-04", "2023-09-05", "2023-09-06", "2023-09-07", "2023-09-08",
+				"2023-09-09", "2023-09-10", "2023-09-11"},
+			[]int{2, 0, 0, 0, 0, 0, 0, 1}},
+		{models.GroupWeek, []string{"2023-W36", "2023-W37"}, []int{2, 1}},
+		{models.GroupMonth, []string{"2023-09"}, []int{3}},
+		{models.GroupCategory, []string{models.PomodoCategory, models.ShortBreakCategory}, []int{3, 0}},
+		{models.GroupTag, []string{models.NoTag, "docs", "work"}, []int{1, 1, 2}},
+		{models.GroupProject, []string{models.NoTag, "pomodoro-go", "site"}, []int{1, 1, 1}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.group, func(t *testing.T) {
+			r, err := models.NewReport(ctx, config, day(11, 12), day(4, 12), tc.group)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Days != 8 {
+				t.Errorf("Expected 8 days, got %d", r.Days)
+			}
+			if len(r.Rows) != len(tc.keys) {
+				t.Fatalf("Expected %d rows, got %+v", len(tc.keys), r.Rows)
+			}
+			for n, row := range r.Rows {
+				if row.Key != tc.keys[n] || row.Pomodoros != tc.pomos[n] {
+					t.Errorf("Row %d: expected %s with %d pomodoros, got %+v",
+						n, tc.keys[n], tc.pomos[n], row)
+				}
+			}
+
+			exp := models.ReportRow{Key: "Total", Pomodoros: 3, Breaks: 1, Canceled: 1,
+				Focus: 85 * time.Minute, Break: 5 * time.Minute}
+			if r.Total != exp {
+				t.Errorf("Expected total %+v, got %+v", exp, r.Total)
+			}
+			if r.FocusPerDay() != 85*time.Minute/8 {
+				t.Errorf("Expected focus per day %s, got %s", 85*time.Minute/8, r.FocusPerDay())
+			}
+		})
+	}
+
+	_, err = models.NewReport(ctx, config, day(4, 12), day(4, 12), "year")
+	if !errors.Is(err, models.ErrInvalidGroup) {
+		t.Errorf("Expected error %q, got %v", models.ErrInvalidGroup, err)
+	}
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// DailyBackup snapshots the repository into dir once per day and keeps
+// only the newest keep snapshots. Snapshots are named after name with
+// the date appended. Returns the path of the new snapshot or an empty
+// string if the one for day already exists.
+func DailyBackup(ctx context.Context, b models.Backuper, dir, name string,
+	day time.Time, keep int) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(filepath.Base(name), ext)
+	dest := filepath.Join(dir,
+		fmt.Sprintf("%s-%s%s", base, day.Format(time.DateOnly), ext))
+
+	created := ""
+	_, err := os.Stat(dest)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if err := b.Backup(ctx, dest); err != nil {
+			return "", err
+		}
+		created = dest
+	case err != nil:
+		return "", err
+	}
+
+	if keep <= 0 {
+		return created, nil
+	}
+
+	// Dated names sort in chronological order
+	snapshots, err := filepath.Glob(filepath.Join(dir, base+"-????-??-??"+ext))
+	if err != nil {
+		return created, err
+	}
+	sort.Strings(snapshots)
+
+	for len(snapshots) > keep {
+		if err := os.Remove(snapshots[0]); err != nil {
+			return created, err
+		}
+		snapshots = snapshots[1:]
+	}
+	return created, nil
+}
@@ -0,0 +1,184 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/repository"
+)
+
+type backupRepo interface {
+	models.Repository
+	models.Backuper
+}
+
+func TestJSONLBackupRestore(t *testing.T) {
+	testBackupRestore(t, func(path string) (backupRepo, error) {
+		return repository.NewJSONLRepo(path)
+	})
+}
+
+func testBackupRestore(t *testing.T, newRepo func(path string) (backupRepo, error)) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	repo, err := newRepo(filepath.Join(dir, "pomo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := repo.Create(ctx, models.Interval{
+		Category:   models.PomodoCategory,
+		State:      models.StateDone,
+		TimeActual: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := filepath.Join(dir, "snapshot")
+	if err := repo.Backup(ctx, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Backup(ctx, snapshot); !errors.Is(err, os.ErrExist) {
+		t.Errorf("Expected error %q, got %v", os.ErrExist, err)
+	}
+
+	// Changes after the snapshot are rolled back on restore
+	if _, err := repo.Create(ctx, models.Interval{Category: models.ShortBreakCategory}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Restore(ctx, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	last, err := repo.Last(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.ID != id || last.TimeActual != time.Minute {
+		t.Errorf("Expected restored interval %d, got %+v", id, last)
+	}
+
+	garbage := filepath.Join(dir, "garbage")
+	if err := os.WriteFile(garbage, []byte("not a backup"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Restore(ctx, garbage); !errors.Is(err, models.ErrInvalidBackup) {
+		t.Errorf("Expected error %q, got %v", models.ErrInvalidBackup, err)
+	}
+	if _, err := repo.ByID(ctx, id); err != nil {
+		t.Errorf("Expected repository intact after failed restore, got %v", err)
+	}
+}
+
+// Backups with a torn last line are restored up to it and left as they are
+func TestJSONLRestoreTorn(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	repo, err := repository.NewJSONLRepo(filepath.Join(dir, "pomo.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	id, err := repo.Create(ctx, models.Interval{Category: models.PomodoCategory})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := filepath.Join(dir, "snapshot.jsonl")
+	if err := repo.Backup(ctx, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(snapshot, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"upd`)
+	f.Close()
+	if err := os.Chmod(snapshot, 0o444); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Create(ctx, models.Interval{Category: models.ShortBreakCategory}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Restore(ctx, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	last, err := repo.Last(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.ID != id {
+		t.Errorf("Expected restored interval %d, got %+v", id, last)
+	}
+	after, err := os.ReadFile(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Errorf("Expected backup unchanged, got %q", after)
+	}
+}
+
+func TestDailyBackup(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	repo, err := repository.NewJSONLRepo(filepath.Join(dir, "pomo.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	backups := filepath.Join(dir, "backups")
+	day := time.Date(2023, 9, 1, 12, 0, 0, 0, time.Local)
+
+	for n := 0; n < 5; n++ {
+		path, err := repository.DailyBackup(ctx, repo, backups, "pomo.jsonl", day.AddDate(0, 0, n), 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path == "" {
+			t.Fatalf("Expected backup for day %d", n)
+		}
+	}
+
+	path, err := repository.DailyBackup(ctx, repo, backups, "pomo.jsonl", day.AddDate(0, 0, 4), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "" {
+		t.Errorf("Expected no second backup for the same day, got %q", path)
+	}
+
+	files, err := filepath.Glob(filepath.Join(backups, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{
+		filepath.Join(backups, "pomo-2023-09-03.jsonl"),
+		filepath.Join(backups, "pomo-2023-09-04.jsonl"),
+		filepath.Join(backups, "pomo-2023-09-05.jsonl"),
+	}
+	if len(files) != len(exp) {
+		t.Fatalf("Expected %v, got %v", exp, files)
+	}
+	for n := range exp {
+		if files[n] != exp[n] {
+			t.Errorf("Expected %q, got %q", exp[n], files[n])
+		}
+	}
+}
@@ -1,9 +1,9 @@
-// go:build inmemory
-
 package repository
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -23,7 +23,7 @@ func NewInMemoryRepo() *InMemoryRepo {
 	}
 }
 
-func (in *InMemoryRepo) Create(i models.Interval) (int64, error) {
+func (in *InMemoryRepo) Create(ctx context.Context, i models.Interval) (int64, error) {
 	in.Lock()
 	defer in.Unlock()
 
@@ -33,19 +33,43 @@ func (in *InMemoryRepo) Create(i models.Interval) (int64, error) {
 	return i.ID, nil
 }
 
-func (in *InMemoryRepo) Update(i models.Interval) error {
+func (in *InMemoryRepo) Update(ctx context.Context, i models.Interval) error {
 	in.Lock()
 	defer in.Unlock()
 
-	if i.ID == 0 {
+	if i.ID <= 0 || i.ID > int64(len(in.intervals)) {
 		return fmt.Errorf("%w: %d", models.ErrInvalidID, i.ID)
 	}
 
+	// Planned duration and category are fixed on create
+	cur := in.intervals[i.ID-1]
+	i.TimePlanning = cur.TimePlanning
+	i.Category = cur.Category
 	in.intervals[i.ID-1] = i
 	return nil
 }
 
-func (in *InMemoryRepo) Last() (models.Interval, error) {
+func (in *InMemoryRepo) Modify(ctx context.Context, id int64, fn func(*models.Interval) error) error {
+	in.Lock()
+	defer in.Unlock()
+
+	if id <= 0 || id > int64(len(in.intervals)) {
+		return fmt.Errorf("%w: %d", models.ErrInvalidID, id)
+	}
+
+	i := in.intervals[id-1]
+	if err := fn(&i); err != nil {
+		return err
+	}
+	i.ID = id
+	i.TimePlanning = in.intervals[id-1].TimePlanning
+	i.Category = in.intervals[id-1].Category
+
+	in.intervals[id-1] = i
+	return nil
+}
+
+func (in *InMemoryRepo) Last(ctx context.Context) (models.Interval, error) {
 	in.RLock()
 	defer in.RUnlock()
 
@@ -54,16 +78,15 @@ func (in *InMemoryRepo) Last() (models.Interval, error) {
 		return i, models.ErrNoIntervals
 	}
 
-	last := in.intervals[len(in.intervals)-1]
-	return last, nil
+	return latest(in.intervals, 1, isAny)[0], nil
 }
 
-func (in *InMemoryRepo) ByID(id int64) (models.Interval, error) {
+func (in *InMemoryRepo) ByID(ctx context.Context, id int64) (models.Interval, error) {
 	in.RLock()
 	defer in.RUnlock()
 
 	i := models.Interval{}
-	if id == 0 {
+	if id <= 0 || id > int64(len(in.intervals)) {
 		return i, fmt.Errorf("%w: %d", models.ErrInvalidID, id)
 	}
 
@@ -71,21 +94,15 @@ func (in *InMemoryRepo) ByID(id int64) (models.Interval, error) {
 	return i, nil
 }
 
-func (in *InMemoryRepo) Breaks(count int) ([]models.Interval, error) {
-	breaks := []models.Interval{}
+func (in *InMemoryRepo) Breaks(ctx context.Context, count int) ([]models.Interval, error) {
+	in.RLock()
+	defer in.RUnlock()
 
-	for i := len(in.intervals) - 1; i >= 0; i-- {
-		if in.intervals[i].Category != models.PomodoCategory {
-			breaks = append(breaks, in.intervals[i])
-		}
-		if len(breaks) == count {
-			return breaks, nil
-		}
-	}
-	return breaks, nil
+	return latest(in.intervals, count, isBreak), nil
 }
-func (in *InMemoryRepo) CategorySummary(day time.Time, filter string) (time.Duration, error) {
-	// Return daily summary
+
+func (in *InMemoryRepo) CategorySummary(ctx context.Context, start, end time.Time, filter string) (time.Duration, error) {
+	// Return summary for [start, end)
 	in.RLock()
 	defer in.RUnlock()
 
@@ -93,8 +110,7 @@ func (in *InMemoryRepo) CategorySummary(day time.Time, filter string) (time.Dura
 	filter = strings.Trim(filter, "%")
 
 	for _, i := range in.intervals {
-		if i.TimeStart.Year() == day.Year() &&
-			i.TimeStart.YearDay() == day.YearDay() {
+		if !i.TimeStart.Before(start) && i.TimeStart.Before(end) {
 			if strings.Contains(i.Category, filter) {
 				d += i.TimeActual
 			}
@@ -102,3 +118,63 @@ func (in *InMemoryRepo) CategorySummary(day time.Time, filter string) (time.Dura
 	}
 	return d, nil
 }
+
+func (in *InMemoryRepo) Range(ctx context.Context, start, end time.Time,
+	fn func(models.Interval) error) error {
+	in.RLock()
+	data := inRange(in.intervals, start, end)
+	in.RUnlock()
+
+	for _, i := range data {
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Return intervals started in [start, end) ordered by start time
+func inRange(intervals []models.Interval, start, end time.Time) []models.Interval {
+	data := []models.Interval{}
+	for _, i := range intervals {
+		if !i.TimeStart.Before(start) && i.TimeStart.Before(end) {
+			data = append(data, i)
+		}
+	}
+
+	sort.SliceStable(data, func(a, b int) bool {
+		return data[a].TimeStart.Before(data[b].TimeStart)
+	})
+	return data
+}
+
+func isAny(models.Interval) bool { return true }
+
+func isBreak(i models.Interval) bool { return i.Category != models.PomodoCategory }
+
+// Return the last n intervals matching keep, latest first. Intervals are
+// ordered by start time, imported history can have higher IDs than the
+// current interval, and those not started yet come last.
+func latest(intervals []models.Interval, n int, keep func(models.Interval) bool) []models.Interval {
+	data := []models.Interval{}
+	for _, i := range intervals {
+		if keep(i) {
+			data = append(data, i)
+		}
+	}
+
+	sort.SliceStable(data, func(a, b int) bool {
+		x, y := data[a], data[b]
+		if x.TimeStart.IsZero() != y.TimeStart.IsZero() {
+			return x.TimeStart.IsZero()
+		}
+		if !x.TimeStart.Equal(y.TimeStart) {
+			return x.TimeStart.After(y.TimeStart)
+		}
+		return x.ID > y.ID
+	})
+	if n < len(data) {
+		data = data[:n]
+	}
+	return data
+}
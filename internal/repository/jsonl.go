@@ -0,0 +1,483 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+const (
+	opCreate = "create"
+	opUpdate = "update"
+
+	// Compact the log when it holds this many superseded events
+	compactAfter = 1024
+)
+
+// jsonlEvent is a single line of the log
+type jsonlEvent struct {
+	Op              string    `json:"op"`
+	ID              int64     `json:"id"`
+	StartTime       time.Time `json:"start_time"`
+	PlannedDuration int64     `json:"planned_duration"`
+	ActualDuration  int64     `json:"actual_duration"`
+	Category        string    `json:"category"`
+	State           int       `json:"state"`
+	Task            string    `json:"task,omitempty"`
+	Tags            []string  `json:"tags,omitempty"`
+	GitDir          string    `json:"git_dir,omitempty"`
+	HeadStart       string    `json:"head_start,omitempty"`
+	HeadEnd         string    `json:"head_end,omitempty"`
+	Project         string    `json:"project,omitempty"`
+}
+
+func newJSONLEvent(op string, i models.Interval) jsonlEvent {
+	return jsonlEvent{
+		Op:              op,
+		ID:              i.ID,
+		StartTime:       i.TimeStart.UTC(),
+		PlannedDuration: int64(i.TimePlanning),
+		ActualDuration:  int64(i.TimeActual),
+		Category:        i.Category,
+		State:           i.State,
+		Task:            i.Task,
+		Tags:            i.Tags,
+		GitDir:          i.GitDir,
+		HeadStart:       i.HeadStart,
+		HeadEnd:         i.HeadEnd,
+		Project:         i.Project,
+	}
+}
+
+func (e jsonlEvent) interval() models.Interval {
+	return models.Interval{
+		ID:           e.ID,
+		Category:     e.Category,
+		State:        e.State,
+		TimeStart:    e.StartTime,
+		TimePlanning: time.Duration(e.PlannedDuration),
+		TimeActual:   time.Duration(e.ActualDuration),
+		Task:         e.Task,
+		Tags:         e.Tags,
+		GitDir:       e.GitDir,
+		HeadStart:    e.HeadStart,
+		HeadEnd:      e.HeadEnd,
+		Project:      e.Project,
+	}
+}
+
+type jsonlRepo struct {
+	sync.Mutex
+	path      string
+	file      *os.File
+	lock      *os.File
+	offset    int64
+	events    int
+	intervals []models.Interval
+}
+
+// Open append-only JSON-lines repository.
+// Every change is appended as an event and the whole log is replayed
+// into memory on load. Other processes are serialized by a lock file
+// next to the log.
+func NewJSONLRepo(path string) (*jsonlRepo, error) {
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &jsonlRepo{
+		path: path,
+		lock: lock,
+	}
+
+	if err := r.withLock(context.Background(), func() error { return nil }); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Close releases the log and the lock file
+func (r *jsonlRepo) Close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	if lerr := r.lock.Close(); err == nil {
+		err = lerr
+	}
+	return err
+}
+
+// withLock runs fn holding both the in-process mutex and the file lock,
+// after catching up with events written by other processes
+func (r *jsonlRepo) withLock(ctx context.Context, fn func() error) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := lockFile(r.lock); err != nil {
+		return err
+	}
+	defer unlockFile(r.lock)
+
+	if err := r.sync(); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+
+	if r.events-len(r.intervals) >= compactAfter {
+		return r.compact()
+	}
+	return nil
+}
+
+// sync reloads the log if it was replaced and reads appended events
+func (r *jsonlRepo) sync() error {
+	st, err := os.Stat(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		st = nil
+	} else if err != nil {
+		return err
+	}
+
+	if r.file != nil {
+		cur, err := r.file.Stat()
+		if err != nil {
+			return err
+		}
+		if st == nil || !os.SameFile(cur, st) || st.Size() < r.offset {
+			r.file.Close()
+			r.file = nil
+		}
+	}
+
+	if r.file == nil {
+		f, err := os.OpenFile(r.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return err
+		}
+		r.file = f
+		r.offset = 0
+		r.events = 0
+		r.intervals = []models.Interval{}
+	}
+
+	return r.replay(true)
+}
+
+// replay applies events from the current offset to the end of the log.
+// An unterminated last line is left over from a crashed writer; it is
+// cut off if repair is set and skipped otherwise, unless it is the only
+// line so that garbage is not read as an empty log.
+func (r *jsonlRepo) replay(repair bool) error {
+	if _, err := r.file.Seek(r.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	rd := bufio.NewReader(r.file)
+	for {
+		line, err := rd.ReadBytes('\n')
+		if err == io.EOF {
+			switch {
+			case len(line) == 0:
+				return nil
+			case repair:
+				return r.file.Truncate(r.offset)
+			case r.offset == 0:
+				return fmt.Errorf("%s: unterminated line", r.path)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			e := jsonlEvent{}
+			if err := json.Unmarshal(line, &e); err != nil {
+				return fmt.Errorf("%s: offset %d: %w", r.path, r.offset, err)
+			}
+			if err := r.apply(e); err != nil {
+				return fmt.Errorf("%s: offset %d: %w", r.path, r.offset, err)
+			}
+			r.events++
+		}
+		r.offset += int64(len(line))
+	}
+}
+
+func (r *jsonlRepo) apply(e jsonlEvent) error {
+	switch e.Op {
+	case opCreate:
+		if e.ID != int64(len(r.intervals)+1) {
+			return fmt.Errorf("%w: %d", models.ErrInvalidID, e.ID)
+		}
+		r.intervals = append(r.intervals, e.interval())
+	case opUpdate:
+		if e.ID <= 0 || e.ID > int64(len(r.intervals)) {
+			return fmt.Errorf("%w: %d", models.ErrInvalidID, e.ID)
+		}
+		r.intervals[e.ID-1] = e.interval()
+	default:
+		return fmt.Errorf("unknown operation %q", e.Op)
+	}
+	return nil
+}
+
+func (r *jsonlRepo) append(e jsonlEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	n, err := r.file.Write(data)
+	r.offset += int64(n)
+	if err != nil {
+		return err
+	}
+	r.events++
+	return r.apply(e)
+}
+
+// compact rewrites the log with a single event per interval and
+// atomically replaces the old one
+func (r *jsonlRepo) compact() error {
+	if err := writeJSONL(r.path, r.intervals); err != nil {
+		return err
+	}
+
+	// Reopen the compacted log
+	r.file.Close()
+	r.file = nil
+	return r.sync()
+}
+
+// writeJSONL atomically replaces path with a log creating intervals
+func writeJSONL(path string, intervals []models.Interval) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, i := range intervals {
+		if err := enc.Encode(newJSONLEvent(opCreate, i)); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// readJSONL replays the log at path without locking or repairing it,
+// skipping an unterminated last line
+func readJSONL(path string) ([]models.Interval, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := &jsonlRepo{
+		path:      path,
+		file:      f,
+		intervals: []models.Interval{},
+	}
+	if err := r.replay(false); err != nil {
+		return nil, err
+	}
+	return r.intervals, nil
+}
+
+// Compact forces compaction of the log
+func (r *jsonlRepo) Compact() error {
+	return r.withLock(context.Background(), r.compact)
+}
+
+func (r *jsonlRepo) Create(ctx context.Context, i models.Interval) (int64, error) {
+	// Create entry in the repository
+	err := r.withLock(ctx, func() error {
+		i.ID = int64(len(r.intervals) + 1)
+		return r.append(newJSONLEvent(opCreate, i))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return i.ID, nil
+}
+
+func (r *jsonlRepo) Update(ctx context.Context, i models.Interval) error {
+	// Update entry in the repository
+	return r.withLock(ctx, func() error {
+		if i.ID <= 0 || i.ID > int64(len(r.intervals)) {
+			return fmt.Errorf("%w: %d", models.ErrInvalidID, i.ID)
+		}
+
+		// Planned duration and category are fixed on create
+		cur := r.intervals[i.ID-1]
+		i.TimePlanning = cur.TimePlanning
+		i.Category = cur.Category
+		return r.append(newJSONLEvent(opUpdate, i))
+	})
+}
+
+func (r *jsonlRepo) Modify(ctx context.Context, id int64, fn func(*models.Interval) error) error {
+	// Read-modify-write entry in the repository
+	return r.withLock(ctx, func() error {
+		if id <= 0 || id > int64(len(r.intervals)) {
+			return fmt.Errorf("%w: %d", models.ErrInvalidID, id)
+		}
+
+		i := r.intervals[id-1]
+		if err := fn(&i); err != nil {
+			return err
+		}
+		i.ID = id
+		i.TimePlanning = r.intervals[id-1].TimePlanning
+		i.Category = r.intervals[id-1].Category
+		return r.append(newJSONLEvent(opUpdate, i))
+	})
+}
+
+func (r *jsonlRepo) Last(ctx context.Context) (models.Interval, error) {
+	// Search last item in the repository
+	i := models.Interval{}
+	err := r.withLock(ctx, func() error {
+		if len(r.intervals) == 0 {
+			return models.ErrNoIntervals
+		}
+		i = latest(r.intervals, 1, isAny)[0]
+		return nil
+	})
+	return i, err
+}
+
+func (r *jsonlRepo) ByID(ctx context.Context, id int64) (models.Interval, error) {
+	// Search item in the repository by ID
+	i := models.Interval{}
+	err := r.withLock(ctx, func() error {
+		if id <= 0 || id > int64(len(r.intervals)) {
+			return fmt.Errorf("%w: %d", models.ErrInvalidID, id)
+		}
+		i = r.intervals[id-1]
+		return nil
+	})
+	return i, err
+}
+
+func (r *jsonlRepo) Breaks(ctx context.Context, n int) ([]models.Interval, error) {
+	// Return last breaks for count
+	breaks := []models.Interval{}
+	err := r.withLock(ctx, func() error {
+		breaks = latest(r.intervals, n, isBreak)
+		return nil
+	})
+	return breaks, err
+}
+
+func (r *jsonlRepo) CategorySummary(ctx context.Context, start, end time.Time, filter string) (time.Duration, error) {
+	// Return summary for [start, end)
+	var d time.Duration
+	filter = strings.Trim(filter, "%")
+
+	err := r.withLock(ctx, func() error {
+		for _, i := range r.intervals {
+			if !i.TimeStart.Before(start) && i.TimeStart.Before(end) {
+				if strings.Contains(i.Category, filter) {
+					d += i.TimeActual
+				}
+			}
+		}
+		return nil
+	})
+	return d, err
+}
+
+func (r *jsonlRepo) Range(ctx context.Context, start, end time.Time,
+	fn func(models.Interval) error) error {
+	// Call fn for intervals in [start, end)
+	data := []models.Interval{}
+	err := r.withLock(ctx, func() error {
+		data = inRange(r.intervals, start, end)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, i := range data {
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *jsonlRepo) Backup(ctx context.Context, dest string) error {
+	// Write a compacted snapshot of the repository to dest
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%s: %w", dest, os.ErrExist)
+	}
+
+	return r.withLock(ctx, func() error {
+		return writeJSONL(dest, r.intervals)
+	})
+}
+
+func (r *jsonlRepo) Restore(ctx context.Context, src string) error {
+	// Replace the repository content with a verified snapshot
+	intervals, err := readJSONL(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", models.ErrInvalidBackup, src, err)
+	}
+
+	return r.withLock(ctx, func() error {
+		if err := writeJSONL(r.path, intervals); err != nil {
+			return err
+		}
+
+		r.file.Close()
+		r.file = nil
+		return r.sync()
+	})
+}
@@ -0,0 +1,27 @@
+package repository_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/repository"
+	"github.com/xor111xor/pomodoro-go/internal/repository/repotest"
+)
+
+func TestInMemoryRepo(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) models.Repository {
+		return repository.NewInMemoryRepo()
+	})
+}
+
+func TestJSONLRepo(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) models.Repository {
+		repo, err := repository.NewJSONLRepo(filepath.Join(t.TempDir(), "pomo.jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
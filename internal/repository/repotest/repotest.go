@@ -0,0 +1,537 @@
+// Package repotest provides a conformance suite for models.Repository
+// implementations.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Factory returns a new empty repository for each test
+type Factory func(t *testing.T) models.Repository
+
+// Run executes the whole suite against repositories returned by newRepo
+func Run(t *testing.T, newRepo Factory) {
+	t.Run("CreateByID", func(t *testing.T) { testCreateByID(t, newRepo(t)) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, newRepo(t)) })
+	t.Run("Modify", func(t *testing.T) { testModify(t, newRepo(t)) })
+	t.Run("FixedOnCreate", func(t *testing.T) { testFixedOnCreate(t, newRepo(t)) })
+	t.Run("Last", func(t *testing.T) { testLast(t, newRepo(t)) })
+	t.Run("LastByStart", func(t *testing.T) { testLastByStart(t, newRepo(t)) })
+	t.Run("NotFound", func(t *testing.T) { testNotFound(t, newRepo(t)) })
+	t.Run("Breaks", func(t *testing.T) { testBreaks(t, newRepo(t)) })
+	t.Run("CategorySummary", func(t *testing.T) { testCategorySummary(t, newRepo(t)) })
+	t.Run("Range", func(t *testing.T) { testRange(t, newRepo(t)) })
+	t.Run("DayBoundary", func(t *testing.T) { testDayBoundary(t, newRepo(t)) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, newRepo(t)) })
+}
+
+func create(t *testing.T, r models.Repository, i models.Interval) models.Interval {
+	ctx := context.Background()
+	t.Helper()
+	id, err := r.Create(ctx, i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.ID = id
+	return i
+}
+
+func compare(t *testing.T, exp, got models.Interval) {
+	t.Helper()
+	if exp.ID != got.ID {
+		t.Errorf("Expected ID %d, got %d", exp.ID, got.ID)
+	}
+	if exp.Category != got.Category {
+		t.Errorf("Expected category %q, got %q", exp.Category, got.Category)
+	}
+	if exp.State != got.State {
+		t.Errorf("Expected state %d, got %d", exp.State, got.State)
+	}
+	if !exp.TimeStart.Equal(got.TimeStart) {
+		t.Errorf("Expected start %s, got %s", exp.TimeStart, got.TimeStart)
+	}
+	if exp.TimePlanning != got.TimePlanning {
+		t.Errorf("Expected planned %s, got %s", exp.TimePlanning, got.TimePlanning)
+	}
+	if exp.TimeActual != got.TimeActual {
+		t.Errorf("Expected actual %s, got %s", exp.TimeActual, got.TimeActual)
+	}
+	if exp.Task != got.Task {
+		t.Errorf("Expected task %q, got %q", exp.Task, got.Task)
+	}
+	if fmt.Sprint(exp.Tags) != fmt.Sprint(got.Tags) {
+		t.Errorf("Expected tags %v, got %v", exp.Tags, got.Tags)
+	}
+	if exp.Project != got.Project {
+		t.Errorf("Expected project %q, got %q", exp.Project, got.Project)
+	}
+	if exp.GitDir != got.GitDir || exp.HeadStart != got.HeadStart || exp.HeadEnd != got.HeadEnd {
+		t.Errorf("Expected git %q %q..%q, got %q %q..%q", exp.GitDir, exp.HeadStart, exp.HeadEnd,
+			got.GitDir, got.HeadStart, got.HeadEnd)
+	}
+}
+
+func testCreateByID(t *testing.T, r models.Repository) {
+	ctx := context.Background()
+	start := time.Date(2023, 9, 1, 10, 0, 0, 0, time.Local)
+
+	var prev int64
+	for n, c := range []string{
+		models.PomodoCategory,
+		models.ShortBreakCategory,
+		models.LongBreakCategory,
+	} {
+		i := create(t, r, models.Interval{
+			Category:     c,
+			State:        models.StateNotStarted,
+			TimeStart:    start.Add(time.Duration(n) * time.Hour),
+			TimePlanning: time.Duration(n+1) * time.Minute,
+			Task:         fmt.Sprintf("task %d", n),
+			Tags:         []string{"tag", c},
+			Project:      "pomodoro-go",
+			GitDir:       "/src/pomodoro",
+			HeadStart:    fmt.Sprintf("%040d", n),
+		})
+		if i.ID <= prev {
+			t.Errorf("Expected ID greater than %d, got %d", prev, i.ID)
+		}
+		prev = i.ID
+
+		got, err := r.ByID(ctx, i.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compare(t, i, got)
+	}
+}
+
+func testUpdate(t *testing.T, r models.Repository) {
+	ctx := context.Background()
+	i := create(t, r, models.Interval{
+		Category:     models.PomodoCategory,
+		TimePlanning: 25 * time.Minute,
+	})
+
+	i.State = models.StateRunning
+	i.TimeStart = time.Date(2023, 9, 1, 10, 0, 0, 0, time.Local)
+	i.TimeActual = 3 * time.Second
+	i.Task = "write report"
+	i.Tags = []string{"work", "docs"}
+	i.Project = "site"
+	i.GitDir = "/src/pomodoro"
+	i.HeadStart = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b"
+	i.HeadEnd = "60303ae22b998861bce3b28f33eec1be758a213c"
+	if err := r.Update(ctx, i); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.ByID(ctx, i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, i, got)
+
+	for _, id := range []int64{0, i.ID + 100} {
+		err := r.Update(ctx, models.Interval{ID: id, Category: models.PomodoCategory})
+		if !errors.Is(err, models.ErrInvalidID) {
+			t.Errorf("Update %d: expected error %q, got %v", id, models.ErrInvalidID, err)
+		}
+	}
+}
+
+func testModify(t *testing.T, r models.Repository) {
+	ctx := context.Background()
+	i := create(t, r, models.Interval{
+		Category:     models.PomodoCategory,
+		State:        models.StateRunning,
+		TimePlanning: 25 * time.Minute,
+	})
+
+	err := r.Modify(ctx, i.ID, func(cur *models.Interval) error {
+		compare(t, i, *cur)
+		cur.TimeActual += time.Second
+		cur.State = models.StatePaused
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.TimeActual = time.Second
+	i.State = models.StatePaused
+
+	got, err := r.ByID(ctx, i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, i, got)
+
+	// Failed modification is not written
+	expErr := errors.New("abort")
+	err = r.Modify(ctx, i.ID, func(cur *models.Interval) error {
+		cur.State = models.StateDone
+		return expErr
+	})
+	if !errors.Is(err, expErr) {
+		t.Errorf("Expected error %q, got %v", expErr, err)
+	}
+	if got, _ = r.ByID(ctx, i.ID); got.State != models.StatePaused {
+		t.Errorf("Expected state %d, got %d", models.StatePaused, got.State)
+	}
+
+	err = r.Modify(ctx, i.ID+100, func(*models.Interval) error { return nil })
+	if !errors.Is(err, models.ErrInvalidID) {
+		t.Errorf("Expected error %q, got %v", models.ErrInvalidID, err)
+	}
+}
+
+func testFixedOnCreate(t *testing.T, r models.Repository) {
+	ctx := context.Background()
+	i := create(t, r, models.Interval{
+		Category:     models.PomodoCategory,
+		TimePlanning: 25 * time.Minute,
+	})
+
+	upd := i
+	upd.Category = models.LongBreakCategory
+	upd.TimePlanning = time.Minute
+	upd.State = models.StateRunning
+	if err := r.Update(ctx, upd); err != nil {
+		t.Fatal(err)
+	}
+	i.State = models.StateRunning
+
+	got, err := r.ByID(ctx, i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, i, got)
+
+	err = r.Modify(ctx, i.ID, func(cur *models.Interval) error {
+		cur.Category = models.ShortBreakCategory
+		cur.TimePlanning = 2 * time.Minute
+		cur.State = models.StatePaused
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.State = models.StatePaused
+
+	got, err = r.ByID(ctx, i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, i, got)
+}
+
+func testLast(t *testing.T, r models.Repository) {
+	ctx := context.Background()
+	if _, err := r.Last(ctx); !errors.Is(err, models.ErrNoIntervals) {
+		t.Errorf("Expected error %q, got %v", models.ErrNoIntervals, err)
+	}
+
+	create(t, r, models.Interval{Category: models.PomodoCategory})
+	exp := create(t, r, models.Interval{Category: models.ShortBreakCategory})
+
+	got, err := r.Last(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, exp, got)
+}
+
+// Imported history is created after the current interval
+func testLastByStart(t *testing.T, r models.Repository) {
+	ctx := context.Background()
+	now := time.Date(2023, 9, 4, 9, 0, 0, 0, time.UTC)
+
+	current := create(t, r, models.Interval{Category: models.ShortBreakCategory,
+		State: models.StatePaused, TimeStart: now})
+	create(t, r, models.Interval{Category: models.PomodoCategory,
+		State: models.StateDone, TimeStart: now.AddDate(0, 0, -1)})
+	older := create(t, r, models.Interval{Category: models.LongBreakCategory,
+		State: models.StateDone, TimeStart: now.AddDate(-1, 0, 0)})
+
+	got, err := r.Last(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, current, got)
+
+	breaks, err := r.Breaks(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(breaks) != 2 {
+		t.Fatalf("Expected 2 breaks, got %d", len(breaks))
+	}
+	compare(t, current, breaks[0])
+	compare(t, older, breaks[1])
+
+	// Intervals not started yet are the latest
+	next := create(t, r, models.Interval{Category: models.PomodoCategory})
+	if got, err = r.Last(ctx); err != nil {
+		t.Fatal(err)
+	}
+	compare(t, next, got)
+}
+
+func testNotFound(t *testing.T, r models.Repository) {
+	ctx := context.Background()
+	for _, id := range []int64{0, -1, 1, 42} {
+		if _, err := r.ByID(ctx, id); !errors.Is(err, models.ErrInvalidID) {
+			t.Errorf("ByID %d: expected error %q, got %v", id, models.ErrInvalidID, err)
+		}
+	}
+}
+
+func testBreaks(t *testing.T, r models.Repository) {
+	ctx := context.Background()
+	breaks, err := r.Breaks(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(breaks) != 0 {
+		t.Errorf("Expected no breaks, got %d", len(breaks))
+	}
+
+	exp := []models.Interval{}
+	for n := 0; n < 5; n++ {
+		create(t, r, models.Interval{Category: models.PomodoCategory})
+		c := models.ShortBreakCategory
+		if n%2 == 0 {
+			c = models.LongBreakCategory
+		}
+		exp = append([]models.Interval{
+			create(t, r, models.Interval{Category: c}),
+		}, exp...)
+	}
+
+	for _, n := range []int{1, 3, 5, 10} {
+		breaks, err := r.Breaks(ctx, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := exp
+		if n < len(want) {
+			want = want[:n]
+		}
+		if len(breaks) != len(want) {
+			t.Fatalf("Breaks(%d): expected %d intervals, got %d", n, len(want), len(breaks))
+		}
+		for k := range want {
+			compare(t, want[k], breaks[k])
+		}
+	}
+}
+
+func testCategorySummary(t *testing.T, r models.Repository) {
+	ctx := context.Background()
+	day := time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)
+	noon := day.Add(12 * time.Hour)
+
+	for _, i := range []models.Interval{
+		{Category: models.PomodoCategory, TimeStart: noon, TimeActual: 25 * time.Minute},
+		{Category: models.PomodoCategory, TimeStart: noon.Add(time.Hour), TimeActual: 20 * time.Minute},
+		{Category: models.ShortBreakCategory, TimeStart: noon, TimeActual: 5 * time.Minute},
+		{Category: models.LongBreakCategory, TimeStart: noon, TimeActual: 15 * time.Minute},
+		{Category: models.PomodoCategory, TimeStart: noon.AddDate(0, 0, 1), TimeActual: time.Hour},
+		{Category: models.PomodoCategory, TimeStart: noon.AddDate(-1, 0, 0), TimeActual: time.Hour},
+	} {
+		create(t, r, i)
+	}
+
+	testCases := []struct {
+		day    time.Time
+		filter string
+		exp    time.Duration
+	}{
+		{day, models.PomodoCategory, 45 * time.Minute},
+		{day, "%Break", 20 * time.Minute},
+		{day.AddDate(0, 0, 1), models.PomodoCategory, time.Hour},
+		{day.AddDate(0, 0, 1), "%Break", 0},
+		{day.AddDate(0, 0, -1), models.PomodoCategory, 0},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s/%s", tc.day.Format(time.DateOnly), tc.filter), func(t *testing.T) {
+			d, err := r.CategorySummary(ctx, tc.day, tc.day.AddDate(0, 0, 1), tc.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d != tc.exp {
+				t.Errorf("Expected %s, got %s", tc.exp, d)
+			}
+		})
+	}
+}
+
+func testDayBoundary(t *testing.T, r models.Repository) {
+	ctx := context.Background()
+	tokyo := time.FixedZone("JST", 9*60*60)
+	berlin := time.FixedZone("CEST", 2*60*60)
+
+	// Day starting 04:00 in Berlin
+	start := time.Date(2023, 9, 2, 4, 0, 0, 0, berlin)
+	end := start.AddDate(0, 0, 1)
+
+	for _, i := range []models.Interval{
+		// Late night session still belongs to the previous day
+		{TimeStart: start.Add(-time.Second), TimeActual: time.Minute},
+		{TimeStart: start, TimeActual: 2 * time.Minute},
+		// Same instants recorded with other offsets
+		{TimeStart: start.In(tokyo), TimeActual: 4 * time.Minute},
+		{TimeStart: end.Add(-time.Second).In(time.UTC), TimeActual: 8 * time.Minute},
+		{TimeStart: end.In(tokyo), TimeActual: 16 * time.Minute},
+	} {
+		i.Category = models.PomodoCategory
+		create(t, r, i)
+	}
+
+	testCases := []struct {
+		start, end time.Time
+		exp        time.Duration
+	}{
+		{start.AddDate(0, 0, -1), start, time.Minute},
+		{start, end, 14 * time.Minute},
+		{end.In(time.UTC), end.AddDate(0, 0, 1), 16 * time.Minute},
+	}
+	for _, tc := range testCases {
+		d, err := r.CategorySummary(ctx, tc.start, tc.end, models.PomodoCategory)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d != tc.exp {
+			t.Errorf("[%s, %s): expected %s, got %s", tc.start, tc.end, tc.exp, d)
+		}
+	}
+}
+
+func testRange(t *testing.T, r models.Repository) {
+	ctx := context.Background()
+	start := time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+
+	// Created out of order like imported history
+	late := create(t, r, models.Interval{Category: models.PomodoCategory, TimeStart: end.Add(-time.Second)})
+	create(t, r, models.Interval{Category: models.PomodoCategory, TimeStart: end})
+	early := create(t, r, models.Interval{Category: models.ShortBreakCategory, TimeStart: start})
+	create(t, r, models.Interval{Category: models.PomodoCategory, TimeStart: start.Add(-time.Second)})
+	middle := create(t, r, models.Interval{
+		Category:  models.LongBreakCategory,
+		TimeStart: start.Add(12 * time.Hour).In(time.FixedZone("UTC-7", -7*60*60)),
+	})
+
+	got := []models.Interval{}
+	err := r.Range(ctx, start, end, func(i models.Interval) error {
+		got = append(got, i)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []models.Interval{early, middle, late}
+	if len(got) != len(exp) {
+		t.Fatalf("Expected %d intervals, got %d", len(exp), len(got))
+	}
+	for n := range exp {
+		compare(t, exp[n], got[n])
+	}
+
+	// Stop at the first error
+	expErr := errors.New("stop")
+	calls := 0
+	err = r.Range(ctx, start, end, func(models.Interval) error {
+		calls++
+		return expErr
+	})
+	if !errors.Is(err, expErr) {
+		t.Errorf("Expected error %q, got %v", expErr, err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
+
+func testConcurrency(t *testing.T, r models.Repository) {
+	ctx := context.Background()
+	const workers, count = 4, 10
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = map[int64]bool{}
+	)
+
+	shared := create(t, r, models.Interval{Category: models.PomodoCategory})
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < count; n++ {
+				id, err := r.Create(ctx, models.Interval{Category: models.PomodoCategory})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if err := r.Update(ctx, models.Interval{
+					ID:         id,
+					Category:   models.PomodoCategory,
+					State:      models.StateDone,
+					TimeActual: time.Second,
+				}); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := r.Breaks(ctx, 3); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := r.Modify(ctx, shared.ID, func(i *models.Interval) error {
+					i.TimeActual += time.Second
+					return nil
+				}); err != nil {
+					t.Error(err)
+					return
+				}
+
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("Duplicate ID %d", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for id := range seen {
+		i, err := r.ByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.State != models.StateDone {
+			t.Errorf("Expected state %d for %d, got %d", models.StateDone, id, i.State)
+		}
+	}
+	if len(seen) != workers*count {
+		t.Errorf("Expected %d intervals, got %d", workers*count, len(seen))
+	}
+
+	i, err := r.ByID(ctx, shared.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := workers * count * time.Second; i.TimeActual != exp {
+		t.Errorf("Expected %s after concurrent modifications, got %s", exp, i.TimeActual)
+	}
+}
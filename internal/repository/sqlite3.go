@@ -1,11 +1,15 @@
-// go:build !inmemory
+//go:build !inmemory && !jsonl
 
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/xor111xor/pomodoro-go/internal/models"
+	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -20,15 +24,42 @@ const (
 		"state" INTEGER DEFAULT 1,
 		PRIMARY KEY("id")
 		);`
+
+	// Latest intervals first: by start time, imported history can have
+	// higher IDs than the current interval, and those not started last
+	orderLatest string = `ORDER BY julianday(start_time) = julianday(?) DESC,
+	julianday(start_time) DESC, id DESC`
+
+	// WAL lets readers in other processes work while we write,
+	// the busy timeout waits for their locks instead of failing
+	// with "database is locked", and immediate transactions take
+	// the write lock before reading so read-modify-write is atomic.
+	sqlite3Options string = "_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
 )
 
+// Schema changes applied in order, PRAGMA user_version counts
+// the applied ones
+var migrations = []string{
+	`ALTER TABLE "interval" ADD COLUMN "task" TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE "interval" ADD COLUMN "tags" TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE "interval" ADD COLUMN "git_dir" TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE "interval" ADD COLUMN "head_start" TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE "interval" ADD COLUMN "head_end" TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE "interval" ADD COLUMN "project" TEXT NOT NULL DEFAULT ''`,
+}
+
 type dbRepo struct {
 	db *sql.DB
 	sync.RWMutex
 }
 
 func NewSQLite3Repo(dbfile string) (*dbRepo, error) {
-	db, err := sql.Open("sqlite3", dbfile)
+	sep := "?"
+	if strings.Contains(dbfile, "?") {
+		sep = "&"
+	}
+
+	db, err := sql.Open("sqlite3", dbfile+sep+sqlite3Options)
 	if err != nil {
 		return nil, err
 	}
@@ -42,26 +73,83 @@ func NewSQLite3Repo(dbfile string) (*dbRepo, error) {
 	if _, err := db.Exec(createTableInterval); err != nil {
 		return nil, err
 	}
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
 	return &dbRepo{
 		db: db,
 	}, nil
 }
 
-func (r *dbRepo) Create(i models.Interval) (int64, error) {
+// Bring the schema up to date, other processes may race us
+func migrate(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var version int
+	if err := tx.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version >= len(migrations) {
+		return nil
+	}
+
+	for _, m := range migrations[version:] {
+		if _, err := tx.Exec(m); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version=%d", len(migrations))); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// Scan row of SELECT * FROM interval
+func scanInterval(row scanner) (models.Interval, error) {
+	i := models.Interval{}
+	var tags string
+	err := row.Scan(&i.ID, &i.TimeStart, &i.TimePlanning,
+		&i.TimeActual, &i.Category, &i.State, &i.Task, &tags,
+		&i.GitDir, &i.HeadStart, &i.HeadEnd, &i.Project)
+	i.Tags = splitTags(tags)
+	return i, err
+}
+
+func joinTags(tags []string) string {
+	return strings.Join(tags, ",")
+}
+
+func splitTags(tags string) []string {
+	if tags == "" {
+		return nil
+	}
+	return strings.Split(tags, ",")
+}
+
+func (r *dbRepo) Create(ctx context.Context, i models.Interval) (int64, error) {
 	// Create entry in the repository
 	r.Lock()
 	defer r.Unlock()
 
 	// Prepare INSERT statements
-	insStmt, err := r.db.Prepare("INSERT INTO interval VALUES(NULL, ?,?,?,?,?)")
+	insStmt, err := r.db.PrepareContext(ctx, "INSERT INTO interval VALUES(NULL, ?,?,?,?,?,?,?,?,?,?,?)")
 	if err != nil {
 		return 0, err
 	}
 	defer insStmt.Close()
 
 	// Exec INSERT statements
-	res, err := insStmt.Exec(i.TimeStart, i.TimePlanning,
-		i.TimeActual, i.Category, i.State)
+	res, err := insStmt.ExecContext(ctx, i.TimeStart.UTC(), i.TimePlanning,
+		i.TimeActual, i.Category, i.State, i.Task, joinTags(i.Tags),
+		i.GitDir, i.HeadStart, i.HeadEnd, i.Project)
 	if err != nil {
 		return 0, err
 	}
@@ -73,54 +161,103 @@ func (r *dbRepo) Create(i models.Interval) (int64, error) {
 	return id, nil
 }
 
-func (r *dbRepo) Update(i models.Interval) error {
+func (r *dbRepo) Update(ctx context.Context, i models.Interval) error {
 	// Update entry in the repository
 	r.Lock()
 	defer r.Unlock()
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := update(ctx, tx, i); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *dbRepo) Modify(ctx context.Context, id int64, fn func(*models.Interval) error) error {
+	// Read-modify-write entry in the repository
+	r.Lock()
+	defer r.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	i, err := scanInterval(tx.QueryRowContext(ctx, "SELECT * FROM interval WHERE id=?", id))
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: %d", models.ErrInvalidID, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := fn(&i); err != nil {
+		return err
+	}
+	i.ID = id
+
+	if err := update(ctx, tx, i); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func update(ctx context.Context, tx *sql.Tx, i models.Interval) error {
 	// Prepare UPDATE statements
-	updStmt, err := r.db.Prepare(
-		"UPDATE interval SET start_time=?, actual_duration=?, state=? WHERE id=?")
+	updStmt, err := tx.PrepareContext(ctx,
+		`UPDATE interval SET start_time=?, actual_duration=?, state=?, task=?, tags=?,
+		git_dir=?, head_start=?, head_end=?, project=? WHERE id=?`)
 	if err != nil {
 		return err
 	}
 	defer updStmt.Close()
 
 	// Exec UPDATE statements
-	res, err := updStmt.Exec(i.TimeStart, i.TimeActual, i.State, i.ID)
+	res, err := updStmt.ExecContext(ctx, i.TimeStart.UTC(), i.TimeActual, i.State,
+		i.Task, joinTags(i.Tags), i.GitDir, i.HeadStart, i.HeadEnd, i.Project, i.ID)
 	if err != nil {
 		return err
 	}
 
 	// UPDATE results
-	_, err = res.RowsAffected()
-	return err
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %d", models.ErrInvalidID, i.ID)
+	}
+	return nil
 }
 
-func (r *dbRepo) ByID(id int64) (models.Interval, error) {
+func (r *dbRepo) ByID(ctx context.Context, id int64) (models.Interval, error) {
 	// Search item in the repository by ID
 	r.RLock()
 	defer r.RUnlock()
 
-	row := r.db.QueryRow("SELECT * FROM interval WHERE id=?", id)
+	row := r.db.QueryRowContext(ctx, "SELECT * FROM interval WHERE id=?", id)
 
-	i := models.Interval{}
-	err := row.Scan(&i.ID, &i.TimeStart, &i.TimePlanning,
-		&i.TimeActual, &i.Category, &i.State)
+	i, err := scanInterval(row)
+
+	if err == sql.ErrNoRows {
+		return i, fmt.Errorf("%w: %d", models.ErrInvalidID, id)
+	}
 
 	return i, err
 }
-func (r *dbRepo) Last() (models.Interval, error) {
+func (r *dbRepo) Last(ctx context.Context) (models.Interval, error) {
 	// Search last item in the repository
 	r.RLock()
 	defer r.RUnlock()
 
-	i := models.Interval{}
-
-	err := r.db.QueryRow("SELECT * FROM interval ORDER BY id desc LIMIT 1").Scan(
-		&i.ID, &i.TimeStart, &i.TimePlanning,
-		&i.TimeActual, &i.Category, &i.State,
-	)
+	i, err := scanInterval(r.db.QueryRowContext(ctx,
+		"SELECT * FROM interval "+orderLatest+" LIMIT 1", time.Time{}))
 
 	if err == sql.ErrNoRows {
 		return i, models.ErrNoIntervals
@@ -133,15 +270,15 @@ func (r *dbRepo) Last() (models.Interval, error) {
 	return i, nil
 }
 
-func (r *dbRepo) Breaks(n int) ([]models.Interval, error) {
+func (r *dbRepo) Breaks(ctx context.Context, n int) ([]models.Interval, error) {
 	// Return last breaks for count
 	r.RLock()
 	defer r.RUnlock()
 
 	stmt := `SELECT * FROM interval WHERE category LIKE '%Break'
-	ORDER BY id DESC LIMIT ?`
+	` + orderLatest + ` LIMIT ?`
 
-	rows, err := r.db.Query(stmt, n)
+	rows, err := r.db.QueryContext(ctx, stmt, time.Time{}, n)
 	if err != nil {
 		return nil, err
 	}
@@ -149,10 +286,7 @@ func (r *dbRepo) Breaks(n int) ([]models.Interval, error) {
 
 	data := []models.Interval{}
 	for rows.Next() {
-		i := models.Interval{}
-		err := rows.Scan(&i.ID, &i.TimeStart, &i.TimePlanning,
-			&i.TimeActual, &i.Category, &i.State)
-
+		i, err := scanInterval(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -167,19 +301,21 @@ func (r *dbRepo) Breaks(n int) ([]models.Interval, error) {
 
 }
 
-func (r *dbRepo) CategorySummary(day time.Time,
+func (r *dbRepo) CategorySummary(ctx context.Context, start, end time.Time,
 	filter string) (time.Duration, error) {
-	//Return a daily summary
+	//Return a summary for [start, end)
 	r.RLock()
 	defer r.RUnlock()
 
+	// julianday compares instants whatever offset was stored
 	stmt := `SELECT sum(actual_duration) FROM interval
 	WHERE category LIKE ? AND
-	strftime('%Y-%m-%d', start_time, 'localtime')=
-	strftime('%Y-%m-%d', ?, 'localtime')`
+	julianday(start_time) >= julianday(?) AND
+	julianday(start_time) < julianday(?)`
 
 	var ds sql.NullInt64
-	err := r.db.QueryRow(stmt, filter, day).Scan(&ds)
+	err := r.db.QueryRowContext(ctx, stmt, filter,
+		start.UTC(), end.UTC()).Scan(&ds)
 
 	var d time.Duration
 	if ds.Valid {
@@ -187,3 +323,98 @@ func (r *dbRepo) CategorySummary(day time.Time,
 	}
 	return d, err
 }
+
+func (r *dbRepo) Range(ctx context.Context, start, end time.Time,
+	fn func(models.Interval) error) error {
+	// Stream intervals in [start, end)
+	r.RLock()
+	defer r.RUnlock()
+
+	stmt := `SELECT * FROM interval WHERE
+	julianday(start_time) >= julianday(?) AND
+	julianday(start_time) < julianday(?)
+	ORDER BY julianday(start_time), id`
+
+	rows, err := r.db.QueryContext(ctx, stmt, start.UTC(), end.UTC())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		i, err := scanInterval(rows)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *dbRepo) Backup(ctx context.Context, dest string) error {
+	// Write a consistent snapshot of the repository to dest
+	r.RLock()
+	defer r.RUnlock()
+
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%s: %w", dest, os.ErrExist)
+	}
+
+	if _, err := r.db.ExecContext(ctx, "VACUUM INTO ?", dest); err != nil {
+		return err
+	}
+	return verifySQLite3(ctx, dest)
+}
+
+func (r *dbRepo) Restore(ctx context.Context, src string) error {
+	// Replace the repository content with a verified snapshot
+	if err := verifySQLite3(ctx, src); err != nil {
+		return err
+	}
+
+	srcDB, err := sql.Open("sqlite3", "file:"+src+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer srcDB.Close()
+
+	r.Lock()
+	defer r.Unlock()
+
+	if err := restore(ctx, r.db, srcDB); err != nil {
+		return err
+	}
+	// Backups of older versions lack the newer columns
+	return migrate(r.db)
+}
+
+// Check that file is an intact pomodoro database
+func verifySQLite3(ctx context.Context, dbfile string) error {
+	if _, err := os.Stat(dbfile); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+dbfile+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var res string
+	if err := db.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&res); err != nil {
+		return fmt.Errorf("%w: %s: %v", models.ErrInvalidBackup, dbfile, err)
+	}
+	if res != "ok" {
+		return fmt.Errorf("%w: %s: integrity check: %s", models.ErrInvalidBackup, dbfile, res)
+	}
+
+	var n int64
+	err = db.QueryRowContext(ctx, "SELECT count(*) FROM interval").Scan(&n)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", models.ErrInvalidBackup, dbfile, err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+//go:build cgo && !inmemory && !jsonl
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Copy the database src over dst
+func restore(ctx context.Context, dst, src *sql.DB) error {
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	// Online backup API copies pages under the proper locks,
+	// so other processes keep a consistent view
+	return dstConn.Raw(func(dst any) error {
+		return srcConn.Raw(func(src any) error {
+			b, err := dst.(*sqlite3.SQLiteConn).Backup("main",
+				src.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			if _, err := b.Step(-1); err != nil {
+				b.Finish()
+				return err
+			}
+			return b.Finish()
+		})
+	})
+}
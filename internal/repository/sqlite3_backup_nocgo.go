@@ -0,0 +1,15 @@
+//go:build !cgo && !inmemory && !jsonl
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Copy the database src over dst
+func restore(ctx context.Context, dst, src *sql.DB) error {
+	// The SQLite3 driver itself only works with cgo
+	return errors.New("restore: sqlite3 requires a cgo build")
+}
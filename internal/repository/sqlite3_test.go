@@ -0,0 +1,172 @@
+//go:build !inmemory && !jsonl
+
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+	"github.com/xor111xor/pomodoro-go/internal/repository"
+	"github.com/xor111xor/pomodoro-go/internal/repository/repotest"
+)
+
+func TestSQLite3ConcurrentConnections(t *testing.T) {
+	ctx := context.Background()
+	const writers, count = 4, 25
+
+	dbfile := filepath.Join(t.TempDir(), "pomo.db")
+
+	// Each repository has its own connection like a separate process
+	repos := []models.Repository{}
+	for w := 0; w < writers; w++ {
+		repo, err := repository.NewSQLite3Repo(dbfile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		repos = append(repos, repo)
+	}
+
+	id, err := repos[0].Create(ctx, models.Interval{
+		Category: models.PomodoCategory,
+		State:    models.StateRunning,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, repo := range repos {
+		wg.Add(1)
+		go func(repo models.Repository) {
+			defer wg.Done()
+			for n := 0; n < count; n++ {
+				if err := repo.Modify(ctx, id, func(i *models.Interval) error {
+					i.TimeActual += time.Second
+					return nil
+				}); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := repo.Create(ctx, models.Interval{Category: models.ShortBreakCategory}); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := repo.Last(ctx); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(repo)
+	}
+	wg.Wait()
+
+	i, err := repos[1].ByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := writers * count * time.Second; i.TimeActual != exp {
+		t.Errorf("Expected %s, got %s", exp, i.TimeActual)
+	}
+
+	last, err := repos[2].Last(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := int64(writers*count + 1); last.ID != exp {
+		t.Errorf("Expected last ID %d, got %d", exp, last.ID)
+	}
+}
+
+func TestSQLite3CanceledContext(t *testing.T) {
+	repo, err := repository.NewSQLite3Repo(filepath.Join(t.TempDir(), "pomo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := repo.Create(ctx, models.Interval{Category: models.PomodoCategory}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected error %q, got %v", context.Canceled, err)
+	}
+	if _, err := repo.Last(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected error %q, got %v", context.Canceled, err)
+	}
+	if _, err := repo.Last(context.Background()); !errors.Is(err, models.ErrNoIntervals) {
+		t.Errorf("Expected error %q, got %v", models.ErrNoIntervals, err)
+	}
+}
+
+func TestSQLite3Repo(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) models.Repository {
+		repo, err := repository.NewSQLite3Repo(filepath.Join(t.TempDir(), "pomo.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	})
+}
+
+func TestSQLite3BackupRestore(t *testing.T) {
+	testBackupRestore(t, func(path string) (backupRepo, error) {
+		return repository.NewSQLite3Repo(path)
+	})
+}
+
+// Backups taken before the newer columns are migrated on restore
+func TestSQLite3RestoreOldSchema(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	old := filepath.Join(dir, "old.db")
+	db, err := sql.Open("sqlite3", old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE "interval" ("id" INTEGER, "start_time" DATETIME NOT NULL,
+		"planned_duration" INTEGER DEFAULT 0, "actual_duration" INTEGER DEFAULT 0,
+		"category" TEXT NOT NULL, "state" INTEGER DEFAULT 1, PRIMARY KEY("id"))`,
+		`INSERT INTO interval VALUES(NULL, '2023-09-04 09:00:00+00:00', 1500000000000,
+		1500000000000, 'Pomodoro', 4)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	repo, err := repository.NewSQLite3Repo(filepath.Join(dir, "pomo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Restore(ctx, old); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := repo.Create(ctx, models.Interval{Category: models.ShortBreakCategory,
+		Task: "write report", Project: "pomodoro-go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := repo.Last(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.ID != id || last.Task != "write report" || last.Project != "pomodoro-go" {
+		t.Errorf("Expected interval %d created after restore, got %+v", id, last)
+	}
+	restored, err := repo.ByID(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Category != models.PomodoCategory || restored.Task != "" {
+		t.Errorf("Expected restored pomodoro, got %+v", restored)
+	}
+}
@@ -0,0 +1,66 @@
+package internal_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+func TestCurrentStatus(t *testing.T) {
+	ctx := context.Background()
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config, err := models.NewConfig(repo, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, _ := config.DayBounds(time.Now())
+	now := start.Add(time.Hour)
+
+	s, err := models.CurrentStatus(ctx, config, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Active() || s.Today != 0 || s.ID != 0 {
+		t.Errorf("Expected empty status, got %+v", s)
+	}
+
+	for _, i := range []models.Interval{
+		{Category: models.PomodoCategory, State: models.StateDone, TimeStart: now.Add(-time.Minute)},
+		{Category: models.PomodoCategory, State: models.StateCanceled, TimeStart: now.Add(-time.Minute)},
+		{Category: models.PomodoCategory, State: models.StateDone, TimeStart: now.AddDate(0, 0, -2)},
+		{
+			Category:     models.ShortBreakCategory,
+			State:        models.StatePaused,
+			TimeStart:    now,
+			TimePlanning: 5 * time.Minute,
+			TimeActual:   time.Minute,
+		},
+	} {
+		if _, err := repo.Create(ctx, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err = models.CurrentStatus(ctx, config, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := models.Status{
+		ID:        4,
+		Category:  models.ShortBreakCategory,
+		State:     models.StatePaused,
+		Planned:   5 * time.Minute,
+		Remaining: 4 * time.Minute,
+		Today:     1,
+	}
+	if s != exp {
+		t.Errorf("Expected %+v, got %+v", exp, s)
+	}
+	if !s.Active() {
+		t.Error("Expected active status")
+	}
+}
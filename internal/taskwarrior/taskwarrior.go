@@ -0,0 +1,266 @@
+// Package taskwarrior offers the pending tasks of Taskwarrior to
+// pomodoros and writes their progress back.
+//
+// Tasks are read with `task export`. A task is started and stopped
+// with the pomodoros spent on it, and its numeric "pomodoros" UDA
+// counts the completed ones. The UDA is defined on every call, it needs
+// no configuration. Pomodoros of the task picked in the dashboard are
+// written back to it by UUID, others like those of --task are matched
+// to tasks by description, preferring the tasks listed last.
+package taskwarrior
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Name of the UDA counting completed pomodoros
+const UDA = "pomodoros"
+
+// Overrides of every call: no prompts or chatter, and the UDA
+var rc = []string{
+	"rc.confirmation=off",
+	"rc.verbose=nothing",
+	"rc.uda." + UDA + ".type=numeric",
+	"rc.uda." + UDA + ".label=Pomodoros",
+}
+
+// Task as exported by Taskwarrior
+type Task struct {
+	UUID        string   `json:"uuid"`
+	ID          int      `json:"id"`
+	Description string   `json:"description"`
+	Project     string   `json:"project"`
+	Tags        []string `json:"tags"`
+	Urgency     float64  `json:"urgency"`
+	Pomodoros   int      `json:"pomodoros"`
+}
+
+// Client is a models.TaskSource, models.TaskCompleter and models.Observer
+type Client struct {
+	filter  []string
+	picked  *models.PickedTask
+	onError func(error)
+	wg      sync.WaitGroup
+
+	mu sync.Mutex
+	// Tasks listed last, most urgent first
+	listed []Task
+	// UUIDs of the picked tasks of pomodoros going on, by interval ID
+	started map[int64]string
+	// Closed when the last write-back is done, they run in order
+	last chan struct{}
+}
+
+// New client of the pending tasks matching filter, like "+work". The
+// task picked in picked, which may be nil, is written back by UUID.
+// onError is told about write-backs which failed.
+func New(filter string, picked *models.PickedTask, onError func(error)) *Client {
+	return &Client{
+		filter:  strings.Fields(filter),
+		picked:  picked,
+		onError: onError,
+		started: map[int64]string{},
+	}
+}
+
+// Pending tasks, most urgent first
+func (c *Client) Pending() ([]Task, error) {
+	args := append([]string{"status:pending"}, c.filter...)
+	tasks, err := export(append(args, "export")...)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(tasks, func(a, b int) bool { return tasks[a].Urgency > tasks[b].Urgency })
+
+	c.mu.Lock()
+	c.listed = tasks
+	c.mu.Unlock()
+	return tasks, nil
+}
+
+// Tasks returns the pending tasks for the dashboard
+func (c *Client) Tasks() ([]models.Task, error) {
+	pending, err := c.Pending()
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]models.Task, 0, len(pending))
+	for _, t := range pending {
+		tasks = append(tasks, models.Task{
+			ID:          t.UUID,
+			Description: t.Description,
+			Project:     t.Project,
+			Tags:        t.Tags,
+		})
+	}
+	return tasks, nil
+}
+
+// Transition starts and stops the task of a pomodoro and counts the
+// completed ones, in the background
+func (c *Client) Transition(ctx context.Context, from int, i models.Interval) {
+	if i.Category != models.PomodoCategory || i.Task == "" {
+		return
+	}
+
+	c.mu.Lock()
+	uuid := c.uuid(i)
+	prev, done := c.last, make(chan struct{})
+	c.last = done
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+		if err := c.writeBack(from, i, uuid); err != nil {
+			c.onError(fmt.Errorf("taskwarrior: %q: %w", i.Task, err))
+		}
+	}()
+}
+
+// Complete marks the task with the UUID t.ID done, or else the pending
+// task with its description
+func (c *Client) Complete(t models.Task) error {
+	if t.ID != "" {
+		return run(t.ID, "done")
+	}
+	found, ok, err := c.find(t.Description)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %q", models.ErrNoTask, t.Description)
+	}
+	return run(found.UUID, "done")
+}
+
+// Wait for the write-backs of the transitions so far
+func (c *Client) Wait() {
+	c.wg.Wait()
+}
+
+// Return the UUID of the picked task of pomodoro i, empty if its task
+// was not picked. It is kept while the pomodoro goes on, as the task of
+// the next one may be picked meanwhile. Called with mu held.
+func (c *Client) uuid(i models.Interval) string {
+	uuid, ok := c.started[i.ID]
+	if !ok {
+		if t, picked := c.picked.Get(); picked && t.ID != "" && t.Description == i.Task {
+			uuid = t.ID
+		}
+	}
+	if models.Ended(i.State) {
+		delete(c.started, i.ID)
+	} else if uuid != "" {
+		c.started[i.ID] = uuid
+	}
+	return uuid
+}
+
+func (c *Client) writeBack(from int, i models.Interval, uuid string) error {
+	if uuid == "" {
+		t, ok, err := c.find(i.Task)
+		if err != nil || !ok {
+			return err
+		}
+		uuid = t.UUID
+	}
+
+	if i.State == models.StateRunning {
+		return run(uuid, "start")
+	}
+	if from == models.StateRunning {
+		if err := run(uuid, "stop"); err != nil {
+			return err
+		}
+	}
+	if i.State != models.StateDone {
+		return nil
+	}
+
+	// Counted from the current value, it may have changed meanwhile
+	current, err := export(uuid, "export")
+	if err != nil {
+		return err
+	}
+	for _, cur := range current {
+		if cur.UUID == uuid {
+			return run(uuid, "modify", fmt.Sprintf("%s:%d", UDA, cur.Pomodoros+1))
+		}
+	}
+	return fmt.Errorf("task %s not found", uuid)
+}
+
+// Return the task described by description, false for none. Tasks are
+// listed again when the last listed ones do not have it.
+func (c *Client) find(description string) (Task, bool, error) {
+	c.mu.Lock()
+	listed := c.listed
+	c.mu.Unlock()
+
+	if t, ok := match(listed, description); ok {
+		return t, true, nil
+	}
+	listed, err := c.Pending()
+	if err != nil {
+		return Task{}, false, err
+	}
+	t, ok := match(listed, description)
+	return t, ok, nil
+}
+
+func match(tasks []Task, description string) (Task, bool) {
+	for _, t := range tasks {
+		if t.Description == description {
+			return t, true
+		}
+	}
+	return Task{}, false
+}
+
+func export(args ...string) ([]Task, error) {
+	out, err := task(args...)
+	if err != nil {
+		return nil, err
+	}
+	tasks := []Task{}
+	if err := json.Unmarshal(out, &tasks); err != nil {
+		return nil, fmt.Errorf("task export: %w", err)
+	}
+	return tasks, nil
+}
+
+// Run command on the task uuid
+func run(uuid string, args ...string) error {
+	_, err := task(append([]string{uuid}, args...)...)
+	return err
+}
+
+// Run task with args, errors carry its message
+func task(args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("task", append(rc[:len(rc):len(rc)], args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("task %s: %w: %s", strings.Join(args, " "), err, msg)
+		}
+		return nil, fmt.Errorf("task %s: %w", strings.Join(args, " "), err)
+	}
+	return stdout.Bytes(), nil
+}
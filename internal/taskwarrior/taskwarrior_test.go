@@ -0,0 +1,205 @@
+//go:build !windows
+
+package taskwarrior
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+const pending = `[
+{"id":1,"uuid":"aaaa-1","description":"Write docs","project":"site","tags":["docs"],"urgency":2.5},
+{"id":2,"uuid":"bbbb-2","description":"Fix parser","urgency":8.1,"pomodoros":2},
+{"id":3,"uuid":"cccc-3","description":"Broken","urgency":1},
+{"id":4,"uuid":"dddd-4","description":"Fix parser","project":"site","urgency":0.5}
+]`
+
+// Put a fake task on PATH logging its arguments, printing pending for
+// export and failing for the task cccc-3
+func fakeTask(t *testing.T) func() []string {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	data := filepath.Join(dir, "pending.json")
+	if err := os.WriteFile(data, []byte(pending), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	script := `#!/bin/sh
+echo "$*" >> "` + log + `"
+case "$*" in
+*cccc-3\ start*) echo "Task 3 is broken." >&2; exit 1 ;;
+*export) cat "` + data + `" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "task"), []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	// Calls without the rc overrides
+	return func() []string {
+		data, err := os.ReadFile(log)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls := []string{}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if !strings.HasPrefix(line, strings.Join(rc, " ")+" ") {
+				t.Errorf("Expected rc overrides, got %q", line)
+			}
+			calls = append(calls, strings.TrimPrefix(line, strings.Join(rc, " ")+" "))
+		}
+		os.Remove(log)
+		return calls
+	}
+}
+
+type errs struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (e *errs) add(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.errs = append(e.errs, err)
+}
+
+func TestPending(t *testing.T) {
+	calls := fakeTask(t)
+	c := New("+work  project:site", nil, func(error) {})
+
+	tasks, err := c.Tasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	descriptions := []string{}
+	for _, task := range tasks {
+		descriptions = append(descriptions, task.Description)
+	}
+	if got := strings.Join(descriptions, ","); got != "Fix parser,Write docs,Broken,Fix parser" {
+		t.Errorf("Expected tasks by urgency, got %q", got)
+	}
+	if tasks[1].ID != "aaaa-1" || tasks[1].Project != "site" || strings.Join(tasks[1].Tags, ",") != "docs" {
+		t.Errorf("Unexpected task %+v", tasks[1])
+	}
+	if got := calls(); strings.Join(got, "|") != "status:pending +work project:site export" {
+		t.Errorf("Unexpected calls %q", got)
+	}
+}
+
+func TestTransition(t *testing.T) {
+	ctx := context.Background()
+	calls := fakeTask(t)
+	e := &errs{}
+	picked := &models.PickedTask{}
+	c := New("", picked, e.add)
+
+	pomodoro := func(task string, state int) models.Interval {
+		return models.Interval{ID: 1, Category: models.PomodoCategory, State: state,
+			Task: task, TimeStart: time.Now()}
+	}
+
+	testCases := []struct {
+		name  string
+		from  int
+		i     models.Interval
+		calls []string
+	}{
+		{"Start", models.StateNotStarted, pomodoro("Fix parser", models.StateRunning),
+			[]string{"status:pending export", "bbbb-2 start"}},
+		{"Pause", models.StateRunning, pomodoro("Fix parser", models.StatePaused),
+			[]string{"bbbb-2 stop"}},
+		{"Resume", models.StatePaused, pomodoro("Fix parser", models.StateRunning),
+			[]string{"bbbb-2 start"}},
+		{"Done", models.StateRunning, pomodoro("Fix parser", models.StateDone),
+			[]string{"bbbb-2 stop", "bbbb-2 export", "bbbb-2 modify pomodoros:3"}},
+		{"Done not started", models.StateNotStarted, pomodoro("Write docs", models.StateDone),
+			[]string{"aaaa-1 export", "aaaa-1 modify pomodoros:1"}},
+		{"Skip", models.StateRunning, pomodoro("Write docs", models.StateSkipped),
+			[]string{"aaaa-1 stop"}},
+		{"Cancel paused", models.StatePaused, pomodoro("Write docs", models.StateCanceled), nil},
+		{"Unknown task", models.StateNotStarted, pomodoro("Lunch", models.StateRunning),
+			[]string{"status:pending export"}},
+		{"No task", models.StateNotStarted, pomodoro("", models.StateRunning), nil},
+		{"Break", models.StateNotStarted, models.Interval{Category: models.ShortBreakCategory,
+			State: models.StateRunning, Task: "Fix parser"}, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c.Transition(ctx, tc.from, tc.i)
+			c.Wait()
+			if got := calls(); strings.Join(got, "|") != strings.Join(tc.calls, "|") {
+				t.Errorf("Expected calls %q, got %q", tc.calls, got)
+			}
+		})
+	}
+	if len(e.errs) != 0 {
+		t.Fatalf("Expected no errors, got %v", e.errs)
+	}
+
+	t.Run("Order", func(t *testing.T) {
+		for _, state := range []int{models.StateRunning, models.StatePaused, models.StateRunning} {
+			from := models.StateRunning
+			if state == models.StateRunning {
+				from = models.StatePaused
+			}
+			c.Transition(ctx, from, pomodoro("Fix parser", state))
+		}
+		c.Wait()
+		if got := strings.Join(calls(), "|"); got != "bbbb-2 start|bbbb-2 stop|bbbb-2 start" {
+			t.Errorf("Expected calls in order of the transitions, got %q", got)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		c.Transition(ctx, models.StateNotStarted, pomodoro("Broken", models.StateRunning))
+		c.Wait()
+		calls()
+		if len(e.errs) != 1 || !strings.Contains(e.errs[0].Error(), "Task 3 is broken.") {
+			t.Errorf("Expected error of task, got %v", e.errs)
+		}
+	})
+
+	// Of the tasks described alike the picked one is written back, also
+	// when the next one is picked while the pomodoro goes on
+	t.Run("Picked", func(t *testing.T) {
+		picked.Pick(models.Task{ID: "dddd-4", Description: "Fix parser"})
+		i := pomodoro("Fix parser", models.StateRunning)
+		i.ID = 2
+		c.Transition(ctx, models.StateNotStarted, i)
+		picked.Pick(models.Task{ID: "aaaa-1", Description: "Write docs"})
+		i.State = models.StateDone
+		c.Transition(ctx, models.StateRunning, i)
+		c.Wait()
+		exp := "dddd-4 start|dddd-4 stop|dddd-4 export|dddd-4 modify pomodoros:1"
+		if got := strings.Join(calls(), "|"); got != exp {
+			t.Errorf("Expected calls %q, got %q", exp, got)
+		}
+	})
+
+	t.Run("Complete", func(t *testing.T) {
+		if err := c.Complete(models.Task{ID: "dddd-4", Description: "Fix parser"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Complete(models.Task{Description: "Fix parser"}); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(calls(), "|"); got != "dddd-4 done|bbbb-2 done" {
+			t.Errorf("Expected tasks done, got %q", got)
+		}
+		if err := c.Complete(models.Task{Description: "Lunch"}); !errors.Is(err, models.ErrNoTask) {
+			t.Errorf("Expected error %q, got %v", models.ErrNoTask, err)
+		}
+	})
+}
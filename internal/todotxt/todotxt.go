@@ -0,0 +1,206 @@
+// Package todotxt offers the open tasks of a todo.txt file to pomodoros
+// and writes their progress back, see http://todotxt.org.
+//
+// Completed pomodoros are counted in a pomo:N tag of their task, and
+// tasks are marked done as todo.sh does, dropping the priority. Only
+// the line of the task changes, the rest of the file is written back
+// byte for byte. Pomodoros are matched to tasks by description, the
+// text after priority and creation date without the pomo tag.
+package todotxt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+var (
+	priority = regexp.MustCompile(`^\(([A-Z])\) `)
+	date     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} `)
+	pomo     = regexp.MustCompile(`(?:^|\s)pomo:(\d+)(?:\s|$)`)
+)
+
+// File is a models.TaskSource, models.TaskCompleter and models.Observer
+type File struct {
+	path    string
+	onError func(error)
+	// Date of completion
+	now func() time.Time
+
+	mu sync.Mutex
+}
+
+// Task of a line
+type task struct {
+	priority    string
+	description string
+}
+
+// New task source of the todo.txt file at path. onError is told about
+// write-backs which failed.
+func New(path string, onError func(error)) *File {
+	return &File{path: path, onError: onError, now: time.Now}
+}
+
+// Parse line, false for blank lines and completed tasks
+func parse(line string) (task, bool) {
+	text := strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "x ") {
+		return task{}, false
+	}
+
+	t := task{}
+	if m := priority.FindStringSubmatch(text); m != nil {
+		t.priority = m[1]
+		text = text[len(m[0]):]
+	}
+	text = strings.TrimPrefix(text, date.FindString(text))
+
+	words := []string{}
+	for _, w := range strings.Fields(text) {
+		if !pomo.MatchString(w) {
+			words = append(words, w)
+		}
+	}
+	t.description = strings.Join(words, " ")
+	return t, t.description != ""
+}
+
+// Tasks returns the open tasks by priority, then in file order
+func (f *File) Tasks() ([]models.Task, error) {
+	f.mu.Lock()
+	data, err := os.ReadFile(f.path)
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := []task{}
+	ids := map[string]int{}
+	for n, line := range strings.SplitAfter(string(data), "\n") {
+		if t, ok := parse(line); ok {
+			if _, ok := ids[t.description]; !ok {
+				ids[t.description] = n + 1
+			}
+			tasks = append(tasks, t)
+		}
+	}
+	// Tasks without priority come last
+	key := func(t task) string {
+		if t.priority == "" {
+			return "["
+		}
+		return t.priority
+	}
+	sort.SliceStable(tasks, func(a, b int) bool { return key(tasks[a]) < key(tasks[b]) })
+
+	res := make([]models.Task, 0, len(tasks))
+	for _, t := range tasks {
+		mt := models.Task{ID: strconv.Itoa(ids[t.description]), Description: t.description}
+		for _, w := range strings.Fields(t.description) {
+			switch {
+			case len(w) > 1 && w[0] == '+' && mt.Project == "":
+				mt.Project = w[1:]
+			case len(w) > 1 && w[0] == '@':
+				mt.Tags = append(mt.Tags, w[1:])
+			}
+		}
+		res = append(res, mt)
+	}
+	return res, nil
+}
+
+// Transition counts the completed pomodoros of a task
+func (f *File) Transition(ctx context.Context, from int, i models.Interval) {
+	if i.Category != models.PomodoCategory || i.State != models.StateDone || i.Task == "" {
+		return
+	}
+	err := f.update(i.Task, countPomodoro)
+	// Pomodoros are spent on other things too
+	if err != nil && !errors.Is(err, models.ErrNoTask) {
+		f.onError(fmt.Errorf("todo.txt: %q: %w", i.Task, err))
+	}
+}
+
+// Complete marks the first open task with the description of t done,
+// line numbers change as the file is edited
+func (f *File) Complete(t models.Task) error {
+	day := f.now().Format("2006-01-02")
+	return f.update(t.Description, func(text string) string {
+		return "x " + day + " " + strings.TrimPrefix(text, priority.FindString(text))
+	})
+}
+
+// Add one to the pomo tag of the text of a line, or add the tag
+func countPomodoro(text string) string {
+	m := pomo.FindStringSubmatchIndex(text)
+	if m == nil {
+		return text + " pomo:1"
+	}
+	n, _ := strconv.Atoi(text[m[2]:m[3]])
+	return text[:m[2]] + strconv.Itoa(n+1) + text[m[3]:]
+}
+
+// Replace the text of the first open task with description by fn of
+// it, keeping the rest of the file
+func (f *File) update(description string, fn func(string) string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Write through links, like a todo.txt synced elsewhere
+	path, err := filepath.EvalSymlinks(f.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	for n, line := range lines {
+		if t, ok := parse(line); !ok || t.description != description {
+			continue
+		}
+		text := strings.TrimRight(line, "\r\n")
+		lines[n] = fn(text) + line[len(text):]
+		return write(path, []byte(strings.Join(lines, "")))
+	}
+	return fmt.Errorf("%w: %q", models.ErrNoTask, description)
+}
+
+// Replace the file at path with data at once, keeping its mode
+func write(path string, data []byte) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(fi.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
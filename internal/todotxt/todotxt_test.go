@@ -0,0 +1,143 @@
+package todotxt
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+const todo = "(B) 2026-10-01 Write report +work @desk @home\n" +
+	"x 2026-10-02 2026-09-30 Old thing\n" +
+	"Call Mom @phone pomo:2 due:2026-10-30\r\n" +
+	"\n" +
+	"(A) Fix parser +pomodoro-go\n" +
+	"Last line without newline"
+
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "todo.txt")
+	if err := os.WriteFile(path, []byte(todo), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	errs := []error{}
+	f := New(path, func(err error) { errs = append(errs, err) })
+	f.now = func() time.Time { return time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC) }
+
+	tasks, err := f.Tasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	descriptions := []string{}
+	for _, task := range tasks {
+		descriptions = append(descriptions, task.Description)
+	}
+	exp := "Fix parser +pomodoro-go|Write report +work @desk @home|" +
+		"Call Mom @phone due:2026-10-30|Last line without newline"
+	if got := strings.Join(descriptions, "|"); got != exp {
+		t.Errorf("Expected tasks\n%s\ngot\n%s", exp, got)
+	}
+	if task := tasks[1]; task.ID != "1" || task.Project != "work" || strings.Join(task.Tags, ",") != "desk,home" {
+		t.Errorf("Unexpected task %+v", task)
+	}
+
+	// Lines of the file after changing line n to line
+	changed := func(n int, line string) string {
+		lines := strings.SplitAfter(todo, "\n")
+		lines[n] = line
+		return strings.Join(lines, "")
+	}
+	check := func(exp string) {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != exp {
+			t.Errorf("Expected file\n%q\ngot\n%q", exp, data)
+		}
+	}
+	done := func(task string) models.Interval {
+		return models.Interval{Category: models.PomodoCategory, State: models.StateDone, Task: task}
+	}
+
+	testCases := []struct {
+		name string
+		from int
+		i    models.Interval
+		exp  string
+	}{
+		{"Count", models.StateRunning, done("Call Mom @phone due:2026-10-30"),
+			changed(2, "Call Mom @phone pomo:3 due:2026-10-30\r\n")},
+		{"First count", models.StateRunning, done("Fix parser +pomodoro-go"),
+			changed(4, "(A) Fix parser +pomodoro-go pomo:1\n")},
+		{"No newline", models.StateNotStarted, done("Last line without newline"),
+			changed(5, "Last line without newline pomo:1")},
+		{"Running", models.StateNotStarted, models.Interval{Category: models.PomodoCategory,
+			State: models.StateRunning, Task: "Fix parser +pomodoro-go"}, todo},
+		{"Break", models.StateRunning, models.Interval{Category: models.ShortBreakCategory,
+			State: models.StateDone, Task: "Fix parser +pomodoro-go"}, todo},
+		{"Skipped", models.StateRunning, models.Interval{Category: models.PomodoCategory,
+			State: models.StateSkipped, Task: "Fix parser +pomodoro-go"}, todo},
+		{"Other task", models.StateRunning, done("Lunch"), todo},
+		{"Completed task", models.StateRunning, done("Old thing"), todo},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(todo), 0o640); err != nil {
+				t.Fatal(err)
+			}
+			f.Transition(ctx, tc.from, tc.i)
+			check(tc.exp)
+		})
+	}
+	if len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+
+	t.Run("Complete", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte(todo), 0o640); err != nil {
+			t.Fatal(err)
+		}
+		task := models.Task{ID: "1", Description: "Write report +work @desk @home"}
+		if err := f.Complete(task); err != nil {
+			t.Fatal(err)
+		}
+		check(changed(0, "x 2026-10-19 2026-10-01 Write report +work @desk @home\n"))
+		if err := f.Complete(task); !errors.Is(err, models.ErrNoTask) {
+			t.Errorf("Expected error %q for completed task, got %v", models.ErrNoTask, err)
+		}
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0o640 {
+			t.Errorf("Expected mode kept, got %s", fi.Mode())
+		}
+	})
+
+	t.Run("Link", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte(todo), 0o640); err != nil {
+			t.Fatal(err)
+		}
+		link := filepath.Join(t.TempDir(), "todo.txt")
+		if err := os.Symlink(path, link); err != nil {
+			t.Skip(err)
+		}
+		linked := New(link, nil)
+		linked.now = f.now
+		if err := linked.Complete(models.Task{Description: "Fix parser +pomodoro-go"}); err != nil {
+			t.Fatal(err)
+		}
+		check(changed(4, "x 2026-10-19 Fix parser +pomodoro-go\n"))
+		if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("Expected link kept, got %v, %v", fi, err)
+		}
+	})
+}
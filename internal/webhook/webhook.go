@@ -0,0 +1,466 @@
+// Package webhook POSTs interval transitions to configured URLs.
+//
+// Every transition is written to an on-disk queue first and delivered
+// from there in order, so transitions survive endpoints being down and
+// processes exiting. Failed deliveries are retried with exponential
+// backoff and moved to the failed directory of the queue once a hook's
+// retries are used up.
+//
+// Requests carry the event in the X-Pomodoro-Event header and, for
+// hooks with a secret, the hex HMAC-SHA256 of the body in
+// X-Pomodoro-Signature as "sha256=<hex>".
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+// Events
+const (
+	EventStart    = "start"
+	EventResume   = "resume"
+	EventPause    = "pause"
+	EventComplete = "complete"
+	EventSkip     = "skip"
+	EventCancel   = "cancel"
+)
+
+var events = []string{EventStart, EventResume, EventPause, EventComplete, EventSkip, EventCancel}
+
+const (
+	DefaultTimeout = 5 * time.Second
+	DefaultRetries = 5
+
+	retryBase = time.Second
+	retryMax  = 5 * time.Minute
+	// Wait of Run while nothing is due
+	pollInterval = 30 * time.Second
+
+	signatureHeader = "X-Pomodoro-Signature"
+	eventHeader     = "X-Pomodoro-Event"
+	deliveryHeader  = "X-Pomodoro-Delivery"
+)
+
+var ErrInvalidHook = fmt.Errorf("Invalid webhook")
+
+// Queue locked by another process
+var errLocked = errors.New("webhook queue locked")
+
+// Hook is an endpoint of the webhooks config list
+type Hook struct {
+	URL string `mapstructure:"url"`
+	// Events POSTed, all of them when empty
+	Events []string `mapstructure:"events"`
+	// Key of the HMAC signature, unsigned when empty
+	Secret string `mapstructure:"secret"`
+	// Zero for DefaultTimeout
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Attempts after the first one before giving up, zero for
+	// DefaultRetries
+	Retries int `mapstructure:"retries"`
+
+	// Stable across restarts, set by New
+	id string
+}
+
+func (h Hook) Validate() error {
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHook, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: %q: want an http or https URL", ErrInvalidHook, h.URL)
+	}
+	for _, e := range h.Events {
+		if !h.known(e) {
+			return fmt.Errorf("%w: %q: unknown event %q, want one of %s",
+				ErrInvalidHook, h.URL, e, strings.Join(events, ", "))
+		}
+	}
+	if h.Timeout < 0 || h.Retries < 0 {
+		return fmt.Errorf("%w: %q: negative timeout or retries", ErrInvalidHook, h.URL)
+	}
+	return nil
+}
+
+// Return id of the hook from what tells it apart from other hooks of
+// the same URL, queued deliveries survive changes of the others
+func (h Hook) key() string {
+	sum := sha256.Sum256([]byte(h.URL + "\n" + h.Secret + "\n" + strings.Join(h.Events, ",")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (h Hook) known(event string) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (h Hook) wants(event string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Body of the POST requests
+type Payload struct {
+	Event    string    `json:"event"`
+	ID       int64     `json:"id"`
+	Category string    `json:"category"`
+	State    string    `json:"state"`
+	Task     string    `json:"task,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+	Project  string    `json:"project,omitempty"`
+	Start    time.Time `json:"start"`
+	Planned  int64     `json:"planned_seconds"`
+	Actual   int64     `json:"actual_seconds"`
+	// Time of the transition
+	Time time.Time `json:"time"`
+}
+
+// Return event of the transition, empty for none
+func Event(from int, i models.Interval) string {
+	switch i.State {
+	case models.StateRunning:
+		if from == models.StatePaused {
+			return EventResume
+		}
+		return EventStart
+	case models.StatePaused:
+		return EventPause
+	case models.StateDone:
+		return EventComplete
+	case models.StateSkipped:
+		return EventSkip
+	case models.StateCanceled:
+		return EventCancel
+	}
+	return ""
+}
+
+// Queued request
+type delivery struct {
+	ID       string          `json:"id"`
+	Hook     string          `json:"hook"`
+	URL      string          `json:"url"`
+	Event    string          `json:"event"`
+	Body     json.RawMessage `json:"body"`
+	Attempts int             `json:"attempts"`
+	Next     time.Time       `json:"next"`
+}
+
+// Sender queues transitions in dir and delivers them to the hooks
+type Sender struct {
+	dir     string
+	hooks   []Hook
+	client  *http.Client
+	onError func(error)
+	wake    chan struct{}
+
+	// Serializes flushes of this process, the lock file those of others
+	mu       sync.Mutex
+	enqueued atomic.Int64
+}
+
+// New sender queueing in dir, errors of deliveries go to onError or the
+// log when nil
+func New(dir string, hooks []Hook, onError func(error)) (*Sender, error) {
+	s := &Sender{
+		dir:     dir,
+		client:  &http.Client{},
+		onError: onError,
+		wake:    make(chan struct{}, 1),
+	}
+	if s.onError == nil {
+		s.onError = func(err error) { log.Print(err) }
+	}
+	for _, h := range hooks {
+		if err := h.Validate(); err != nil {
+			return nil, err
+		}
+		if h.Timeout == 0 {
+			h.Timeout = DefaultTimeout
+		}
+		if h.Retries == 0 {
+			h.Retries = DefaultRetries
+		}
+		h.id = h.key()
+		s.hooks = append(s.hooks, h)
+	}
+	return s, nil
+}
+
+// Transition queues the event of the transition for the hooks wanting it
+func (s *Sender) Transition(ctx context.Context, from int, i models.Interval) {
+	event := Event(from, i)
+	if event == "" {
+		return
+	}
+	body, err := json.Marshal(Payload{
+		Event:    event,
+		ID:       i.ID,
+		Category: i.Category,
+		State:    models.StateName(i.State),
+		Task:     i.Task,
+		Tags:     i.Tags,
+		Project:  i.Project,
+		Start:    i.TimeStart,
+		Planned:  int64(i.TimePlanning.Seconds()),
+		Actual:   int64(i.TimeActual.Seconds()),
+		Time:     time.Now().UTC(),
+	})
+	if err != nil {
+		s.onError(fmt.Errorf("webhook: %w", err))
+		return
+	}
+
+	for _, h := range s.hooks {
+		if !h.wants(event) {
+			continue
+		}
+		d := delivery{Hook: h.id, URL: h.URL, Event: event, Body: body}
+		if err := s.enqueue(d); err != nil {
+			s.onError(fmt.Errorf("webhook: %s: %w", h.URL, err))
+			continue
+		}
+		s.enqueued.Add(1)
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Number of deliveries queued by this process
+func (s *Sender) Enqueued() int {
+	return int(s.enqueued.Load())
+}
+
+// Write d to a new file of the queue, names sort in order of transitions
+func (s *Sender) enqueue(d delivery) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	d.ID = fmt.Sprintf("%019d-%s", time.Now().UnixNano(), hex.EncodeToString(suffix))
+	return s.write(d)
+}
+
+// Write d atomically, readers never see partial files
+func (s *Sender) write(d delivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(s.dir, d.ID+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, d.ID+".json"))
+}
+
+// Run delivers queued transitions until ctx is done
+func (s *Sender) Run(ctx context.Context) {
+	for {
+		wait := s.flush(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Flush delivers the transitions due now, they stay queued when ctx
+// is done first
+func (s *Sender) Flush(ctx context.Context) {
+	s.flush(ctx)
+}
+
+// Deliver due transitions and return the wait until the next is due
+func (s *Sender) flush(ctx context.Context) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockQueue(s.dir)
+	if errors.Is(err, errLocked) {
+		// Another process is delivering
+		return retryBase
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		// Nothing was ever queued
+		return pollInterval
+	}
+	if err != nil {
+		s.onError(fmt.Errorf("webhook: %w", err))
+		return pollInterval
+	}
+	defer unlock()
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		s.onError(fmt.Errorf("webhook: %w", err))
+		return pollInterval
+	}
+	sort.Strings(files)
+
+	wait := pollInterval
+	// Keep deliveries to a hook in order
+	blocked := map[string]bool{}
+	for _, file := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		d, err := read(file)
+		if err != nil {
+			s.onError(fmt.Errorf("webhook: %s: %w", file, err))
+			continue
+		}
+		h, ok := s.hook(d)
+		if !ok {
+			// Hook removed from the config
+			os.Remove(file)
+			continue
+		}
+		if blocked[h.id] {
+			continue
+		}
+		if due := time.Until(d.Next); due > 0 {
+			blocked[h.id] = true
+			wait = min(wait, due)
+			continue
+		}
+
+		err = s.deliver(ctx, h, d)
+		if err == nil {
+			os.Remove(file)
+			continue
+		}
+		blocked[h.id] = true
+		if ctx.Err() != nil {
+			break
+		}
+
+		d.Attempts++
+		if d.Attempts > h.Retries {
+			s.onError(fmt.Errorf("webhook: %s %s: giving up after %d attempts: %w",
+				d.Event, d.URL, d.Attempts, err))
+			if err := s.fail(file); err != nil {
+				s.onError(fmt.Errorf("webhook: %w", err))
+			}
+			continue
+		}
+		s.onError(fmt.Errorf("webhook: %s %s: %w", d.Event, d.URL, err))
+		wait = min(wait, backoff(d.Attempts))
+		d.Next = time.Now().Add(backoff(d.Attempts))
+		if err := s.write(d); err != nil {
+			s.onError(fmt.Errorf("webhook: %w", err))
+		}
+	}
+	return wait
+}
+
+// Return wait before the next attempt after the failed ones
+func backoff(attempts int) time.Duration {
+	d := retryBase
+	for i := 1; i < attempts && d < retryMax; i++ {
+		d *= 2
+	}
+	return min(d, retryMax)
+}
+
+// Return hook of d, deliveries queued by older versions only know the URL
+func (s *Sender) hook(d delivery) (Hook, bool) {
+	for _, h := range s.hooks {
+		if h.id == d.Hook || d.Hook == "" && h.URL == d.URL {
+			return h, true
+		}
+	}
+	return Hook{}, false
+}
+
+func (s *Sender) deliver(ctx context.Context, h Hook, d delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pomodoro-go")
+	req.Header.Set(eventHeader, d.Event)
+	req.Header.Set(deliveryHeader, d.ID)
+	if h.Secret != "" {
+		req.Header.Set(signatureHeader, Sign(h.Secret, d.Body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Move file to the failed directory of the queue
+func (s *Sender) fail(file string) error {
+	dir := filepath.Join(s.dir, "failed")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	return os.Rename(file, filepath.Join(dir, filepath.Base(file)))
+}
+
+func read(file string) (delivery, error) {
+	d := delivery{}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return d, err
+	}
+	return d, json.Unmarshal(data, &d)
+}
+
+// Sign returns the signature header value of body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,251 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xor111xor/pomodoro-go/internal/models"
+)
+
+type received struct {
+	event     string
+	signature string
+	body      []byte
+}
+
+// Endpoint recording requests, failing while down
+type endpoint struct {
+	mu       sync.Mutex
+	down     bool
+	requests []received
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	e.requests = append(e.requests, received{
+		event:     r.Header.Get(eventHeader),
+		signature: r.Header.Get(signatureHeader),
+		body:      body,
+	})
+}
+
+func (e *endpoint) setDown(down bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.down = down
+}
+
+func (e *endpoint) events() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	events := []string{}
+	for _, r := range e.requests {
+		events = append(events, r.event)
+	}
+	return events
+}
+
+func queued(t *testing.T, pattern string) int {
+	t.Helper()
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+func TestSender(t *testing.T) {
+	ctx := context.Background()
+	e := &endpoint{}
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	errs := []error{}
+	s, err := New(dir, []Hook{{URL: srv.URL, Secret: "secret", Retries: 1}},
+		func(err error) { errs = append(errs, err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := models.Interval{
+		ID:           3,
+		Category:     models.PomodoCategory,
+		State:        models.StateRunning,
+		TimeStart:    time.Date(2023, 9, 1, 10, 0, 0, 0, time.UTC),
+		TimePlanning: 25 * time.Minute,
+		Task:         "Write report",
+	}
+
+	t.Run("Deliver", func(t *testing.T) {
+		s.Transition(ctx, models.StateNotStarted, i)
+		s.Flush(ctx)
+		if len(e.requests) != 1 {
+			t.Fatalf("Expected 1 request, got %d", len(e.requests))
+		}
+		r := e.requests[0]
+
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(r.body)
+		if exp := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.signature != exp {
+			t.Errorf("Expected signature %q, got %q", exp, r.signature)
+		}
+
+		p := Payload{}
+		if err := json.Unmarshal(r.body, &p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Event != EventStart || p.ID != 3 || p.State != "Running" ||
+			p.Task != "Write report" || p.Planned != 1500 || !p.Start.Equal(i.TimeStart) {
+			t.Errorf("Unexpected payload %+v", p)
+		}
+		if n := queued(t, filepath.Join(dir, "*.json")); n != 0 {
+			t.Errorf("Expected empty queue, got %d", n)
+		}
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		e.setDown(true)
+		i.State = models.StatePaused
+		s.Transition(ctx, models.StateRunning, i)
+		i.State = models.StateRunning
+		s.Transition(ctx, models.StatePaused, i)
+		s.Flush(ctx)
+		if n := queued(t, filepath.Join(dir, "*.json")); n != 2 {
+			t.Fatalf("Expected 2 queued deliveries, got %d", n)
+		}
+		if len(errs) != 1 {
+			t.Errorf("Expected 1 error, got %v", errs)
+		}
+
+		// Kept until the backoff is over
+		e.setDown(false)
+		s.Flush(ctx)
+		if n := queued(t, filepath.Join(dir, "*.json")); n != 2 {
+			t.Errorf("Expected 2 queued deliveries during backoff, got %d", n)
+		}
+
+		time.Sleep(retryBase)
+		s.Flush(ctx)
+		exp := []string{EventStart, EventPause, EventResume}
+		if got := e.events(); len(got) != 3 || got[1] != exp[1] || got[2] != exp[2] {
+			t.Errorf("Expected events %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("GiveUp", func(t *testing.T) {
+		e.setDown(true)
+		defer e.setDown(false)
+		errs = nil
+		i.State = models.StateDone
+		s.Transition(ctx, models.StateRunning, i)
+		s.Flush(ctx)
+		time.Sleep(retryBase)
+		s.Flush(ctx)
+
+		if n := queued(t, filepath.Join(dir, "*.json")); n != 0 {
+			t.Errorf("Expected empty queue, got %d", n)
+		}
+		if n := queued(t, filepath.Join(dir, "failed", "*.json")); n != 1 {
+			t.Errorf("Expected 1 failed delivery, got %d", n)
+		}
+		if len(errs) != 2 {
+			t.Errorf("Expected 2 errors, got %v", errs)
+		}
+	})
+
+	if s.Enqueued() != 4 {
+		t.Errorf("Expected 4 deliveries queued, got %d", s.Enqueued())
+	}
+}
+
+// Hooks of the same URL keep their own secrets and events
+func TestSameURL(t *testing.T) {
+	ctx := context.Background()
+	e := &endpoint{}
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	s, err := New(t.TempDir(), []Hook{
+		{URL: srv.URL, Secret: "first", Events: []string{EventStart}},
+		{URL: srv.URL, Secret: "second"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := models.Interval{ID: 1, Category: models.PomodoCategory, State: models.StateRunning}
+	s.Transition(ctx, models.StateNotStarted, i)
+	i.State = models.StatePaused
+	s.Transition(ctx, models.StateRunning, i)
+	s.Flush(ctx)
+
+	exp := []string{"first", "second", "second"}
+	if len(e.requests) != len(exp) {
+		t.Fatalf("Expected %d requests, got %d", len(exp), len(e.requests))
+	}
+	for n, r := range e.requests {
+		mac := hmac.New(sha256.New, []byte(exp[n]))
+		mac.Write(r.body)
+		if sig := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.signature != sig {
+			t.Errorf("Request %d: expected signature with %q, got %q", n, exp[n], r.signature)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	e := &endpoint{}
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	ctx := context.Background()
+	s, err := New(t.TempDir(), []Hook{{URL: srv.URL, Events: []string{EventComplete}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tr := range []struct{ from, to int }{
+		{models.StateNotStarted, models.StateRunning},
+		{models.StateRunning, models.StateDone},
+		{models.StateRunning, models.StateSkipped},
+		{models.StateNotStarted, models.StateCanceled},
+	} {
+		s.Transition(ctx, tr.from, models.Interval{ID: 1, State: tr.to})
+	}
+	s.Flush(ctx)
+	if got := e.events(); len(got) != 1 || got[0] != EventComplete {
+		t.Errorf("Expected only %s event, got %v", EventComplete, got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, h := range []Hook{
+		{URL: "localhost:8080/hook"},
+		{URL: "ftp://example.com"},
+		{URL: "http://localhost", Events: []string{"finish"}},
+		{URL: "http://localhost", Retries: -1},
+	} {
+		if err := h.Validate(); !errors.Is(err, ErrInvalidHook) {
+			t.Errorf("%+v: expected error %q, got %v", h, ErrInvalidHook, err)
+		}
+	}
+	if err := (Hook{URL: "https://example.com/hook", Events: []string{EventStart}}).Validate(); err != nil {
+		t.Error(err)
+	}
+}